@@ -0,0 +1,67 @@
+// Command sweep deletes LKE clusters, Linodes of terraform clusters,
+// NodeBalancers and volumes left behind by e2e runs that crashed or were
+// interrupted before their teardown.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/linode/linode-k8s-e2e-tests/framework"
+)
+
+func main() {
+	var (
+		apiToken = os.Getenv("LINODE_API_TOKEN")
+		ttl      = 6 * time.Hour
+		tag      = framework.E2ETag
+		dryRun   bool
+		retained string
+	)
+	flag.StringVar(&apiToken, "api-token", apiToken, "The authentication token to use when sending requests to the Linode API")
+	flag.StringVar(&framework.LinodeURL, "api-url", framework.LinodeURL, "Base URL of the Linode API")
+	flag.DurationVar(&ttl, "ttl", ttl, "Only sweep resources older than this")
+	flag.StringVar(&tag, "tag", tag, "Only sweep resources carrying this tag")
+	flag.BoolVar(&dryRun, "dry-run", dryRun, "Print what would be swept without deleting anything")
+	flag.StringVar(&retained, "retained", "", "Delete the resources listed in a manifest written by --keep-on-failure instead of sweeping by age")
+	flag.Parse()
+	defer glog.Flush()
+
+	if apiToken == "" {
+		fmt.Fprintln(os.Stderr, "LINODE_API_TOKEN or --api-token is required")
+		os.Exit(2)
+	}
+
+	sweeper := framework.NewSweeper(apiToken)
+	sweeper.TTL = ttl
+	sweeper.Tag = tag
+	sweeper.DryRun = dryRun
+
+	if retained != "" {
+		r, err := framework.LoadRetained(retained)
+		if err == nil {
+			err = framework.SweepRetained(context.Background(), r, sweeper)
+		}
+		if err != nil {
+			glog.Flush()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Swept resources of run %s\n", r.RunID)
+		return
+	}
+
+	swept, err := sweeper.Sweep(context.Background())
+	for _, res := range swept {
+		fmt.Printf("%s\t%d\t%s\tcreated %s ago\n", res.Kind, res.ID, res.Label, time.Since(res.Created).Round(time.Minute))
+	}
+	if err != nil {
+		glog.Flush()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,456 @@
+package framework
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// InstallCCM deploys Image as the cloud controller manager of the cluster
+	// before the specs run, see CCMInstaller.
+	InstallCCM = false
+)
+
+const (
+	ccmName      = "ccm-linode"
+	ccmTokenKey  = "apiToken"
+	ccmRegionKey = "region"
+	// ccmLeaseName is the leader election lease of cloud controller managers.
+	ccmLeaseName = "cloud-controller-manager"
+)
+
+// CCMInstaller deploys a linode-cloud-controller-manager image into
+// kube-system. An existing ccm-linode DaemonSet or Deployment is patched to
+// run the image, otherwise a DaemonSet is created together with the API token
+// Secret and RBAC. Restore undoes all of it, for clusters that outlive the run.
+type CCMInstaller struct {
+	Image    string
+	APIToken string
+	Region   string
+
+	kubeClient kubernetes.Interface
+	timeout    time.Duration
+	interval   time.Duration
+
+	originalSecret    *core.Secret
+	originalDaemonSet *apps.DaemonSet
+	originalDeploy    *apps.Deployment
+	created           []TrackedResource
+}
+
+func (f *Framework) NewCCMInstaller(image, apiToken, region string) *CCMInstaller {
+	return &CCMInstaller{
+		Image:      image,
+		APIToken:   apiToken,
+		Region:     region,
+		kubeClient: f.kubeClient,
+		timeout:    Timeout,
+		interval:   RetryInterval,
+	}
+}
+
+// Install deploys the image, waits for the rollout and returns the digest of
+// the image the CCM pods run.
+func (c *CCMInstaller) Install(ctx context.Context) (string, error) {
+	if err := c.ensureSecret(ctx); err != nil {
+		return "", err
+	}
+
+	daemonSets, err := c.kubeClient.AppsV1().DaemonSets(ccmNamespace).List(ctx, metav1.ListOptions{LabelSelector: ccmLabelSelector})
+	if err != nil {
+		return "", err
+	}
+	deployments, err := c.kubeClient.AppsV1().Deployments(ccmNamespace).List(ctx, metav1.ListOptions{LabelSelector: ccmLabelSelector})
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		selector *metav1.LabelSelector
+		created  bool
+	)
+	switch {
+	case len(daemonSets.Items) > 0:
+		ds := daemonSets.Items[0]
+		c.originalDaemonSet = ds.DeepCopy()
+		if err := c.setImage(&ds.Spec.Template); err != nil {
+			return "", errors.Wrapf(err, "DaemonSet %s", ds.Name)
+		}
+		glog.Infof("Patching DaemonSet %s/%s to run %s", ds.Namespace, ds.Name, c.Image)
+		if _, err := c.kubeClient.AppsV1().DaemonSets(ccmNamespace).Update(ctx, &ds, metav1.UpdateOptions{}); err != nil {
+			return "", err
+		}
+		selector = ds.Spec.Selector
+		_, err = waitForDaemonSet(ctx, c.kubeClient, "ccm-rollout", c.timeout, ccmNamespace, ds.Name, ccmRolledOut)
+	case len(deployments.Items) > 0:
+		deploy := deployments.Items[0]
+		c.originalDeploy = deploy.DeepCopy()
+		if err := c.setImage(&deploy.Spec.Template); err != nil {
+			return "", errors.Wrapf(err, "Deployment %s", deploy.Name)
+		}
+		glog.Infof("Patching Deployment %s/%s to run %s", deploy.Namespace, deploy.Name, c.Image)
+		if _, err := c.kubeClient.AppsV1().Deployments(ccmNamespace).Update(ctx, &deploy, metav1.UpdateOptions{}); err != nil {
+			return "", err
+		}
+		selector = deploy.Spec.Selector
+		_, err = waitForDeployment(ctx, c.kubeClient, "ccm-rollout", c.timeout, ccmNamespace, deploy.Name, deploymentRolledOut)
+	default:
+		ds, createErr := c.create(ctx)
+		if createErr != nil {
+			return "", createErr
+		}
+		selector = ds.Spec.Selector
+		created = true
+		_, err = waitForDaemonSet(ctx, c.kubeClient, "ccm-rollout", c.timeout, ccmNamespace, ds.Name, ccmRolledOut)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "waiting for the CCM rollout")
+	}
+
+	digest, err := c.verifyImage(ctx, metav1.FormatLabelSelector(selector))
+	if err != nil || !created {
+		return digest, err
+	}
+	// A CCM the cluster runs out of sight, like the one in the LKE control
+	// plane, may hold on to the lease and the image would never act.
+	return digest, c.verifyLeader(ctx, metav1.FormatLabelSelector(selector))
+}
+
+// Restore puts back the CCM workload and Secret found by Install and deletes
+// what it created.
+func (c *CCMInstaller) Restore(ctx context.Context) error {
+	var errs []error
+
+	if ds := c.originalDaemonSet; ds != nil {
+		current, err := c.kubeClient.AppsV1().DaemonSets(ds.Namespace).Get(ctx, ds.Name, metav1.GetOptions{})
+		if err == nil {
+			current.Spec = ds.Spec
+			_, err = c.kubeClient.AppsV1().DaemonSets(ds.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		}
+		errs = append(errs, errors.Wrapf(err, "restoring DaemonSet %s", ds.Name))
+	}
+	if deploy := c.originalDeploy; deploy != nil {
+		current, err := c.kubeClient.AppsV1().Deployments(deploy.Namespace).Get(ctx, deploy.Name, metav1.GetOptions{})
+		if err == nil {
+			current.Spec = deploy.Spec
+			_, err = c.kubeClient.AppsV1().Deployments(deploy.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		}
+		errs = append(errs, errors.Wrapf(err, "restoring Deployment %s", deploy.Name))
+	}
+	if secret := c.originalSecret; secret != nil {
+		current, err := c.kubeClient.CoreV1().Secrets(secret.Namespace).Get(ctx, secret.Name, metav1.GetOptions{})
+		if err == nil {
+			current.Data = secret.Data
+			_, err = c.kubeClient.CoreV1().Secrets(secret.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		}
+		errs = append(errs, errors.Wrapf(err, "restoring Secret %s", secret.Name))
+	}
+
+	for i := len(c.created) - 1; i >= 0; i-- {
+		res := c.created[i]
+		if err := res.delete(); err != nil && !kerr.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "deleting %s %s", res.Kind, res.Name))
+		}
+	}
+
+	c.originalDaemonSet, c.originalDeploy, c.originalSecret, c.created = nil, nil, nil, nil
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c *CCMInstaller) ensureSecret(ctx context.Context) error {
+	secrets := c.kubeClient.CoreV1().Secrets(ccmNamespace)
+	secret, err := secrets.Get(ctx, ccmName, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: ccmName, Namespace: ccmNamespace},
+			StringData: map[string]string{
+				ccmTokenKey:  c.APIToken,
+				ccmRegionKey: c.Region,
+			},
+		}
+		if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "creating the CCM Secret")
+		}
+		c.track("Secret", ccmNamespace, ccmName, func() error {
+			return secrets.Delete(context.TODO(), ccmName, metav1.DeleteOptions{})
+		})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.APIToken == "" || string(secret.Data[ccmTokenKey]) == c.APIToken {
+		return nil
+	}
+	c.originalSecret = secret.DeepCopy()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[ccmTokenKey] = []byte(c.APIToken)
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return errors.Wrap(err, "updating the CCM Secret")
+}
+
+// setImage points the CCM container of template at the image. Images without
+// a pinned tag are always pulled, so a rebuilt :latest is picked up.
+func (c *CCMInstaller) setImage(template *core.PodTemplateSpec) error {
+	containers := template.Spec.Containers
+	idx := -1
+	for i := range containers {
+		if containers[i].Name == ccmName {
+			idx = i
+		}
+	}
+	if idx < 0 && len(containers) == 1 {
+		idx = 0
+	}
+	if idx < 0 {
+		return errors.Errorf("no %s container", ccmName)
+	}
+	containers[idx].Image = c.Image
+	containers[idx].ImagePullPolicy = pullPolicy(c.Image)
+	return nil
+}
+
+func (c *CCMInstaller) create(ctx context.Context) (*apps.DaemonSet, error) {
+	glog.Infof("Creating DaemonSet %s/%s running %s", ccmNamespace, ccmName, c.Image)
+
+	sa := &core.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: ccmName, Namespace: ccmNamespace}}
+	if _, err := c.kubeClient.CoreV1().ServiceAccounts(ccmNamespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil {
+		return nil, errors.Wrap(err, "creating the CCM ServiceAccount")
+	}
+	c.track("ServiceAccount", ccmNamespace, ccmName, func() error {
+		return c.kubeClient.CoreV1().ServiceAccounts(ccmNamespace).Delete(context.TODO(), ccmName, metav1.DeleteOptions{})
+	})
+
+	if _, err := c.kubeClient.RbacV1().ClusterRoles().Create(ctx, ccmClusterRole(), metav1.CreateOptions{}); err != nil {
+		return nil, errors.Wrap(err, "creating the CCM ClusterRole")
+	}
+	c.track("ClusterRole", "", ccmName, func() error {
+		return c.kubeClient.RbacV1().ClusterRoles().Delete(context.TODO(), ccmName, metav1.DeleteOptions{})
+	})
+
+	binding := &rbac.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: ccmName},
+		RoleRef:    rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "ClusterRole", Name: ccmName},
+		Subjects:   []rbac.Subject{{Kind: rbac.ServiceAccountKind, Name: ccmName, Namespace: ccmNamespace}},
+	}
+	if _, err := c.kubeClient.RbacV1().ClusterRoleBindings().Create(ctx, binding, metav1.CreateOptions{}); err != nil {
+		return nil, errors.Wrap(err, "creating the CCM ClusterRoleBinding")
+	}
+	c.track("ClusterRoleBinding", "", ccmName, func() error {
+		return c.kubeClient.RbacV1().ClusterRoleBindings().Delete(context.TODO(), ccmName, metav1.DeleteOptions{})
+	})
+
+	ds, err := c.kubeClient.AppsV1().DaemonSets(ccmNamespace).Create(ctx, c.daemonSet(), metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating the CCM DaemonSet")
+	}
+	c.track("DaemonSet", ccmNamespace, ccmName, func() error {
+		return c.kubeClient.AppsV1().DaemonSets(ccmNamespace).Delete(context.TODO(), ccmName, *deleteInForeground())
+	})
+	return ds, nil
+}
+
+// ccmClusterRole grants what the upstream ccm-linode manifest does.
+func ccmClusterRole() *rbac.ClusterRole {
+	rule := func(group string, resources []string, verbs ...string) rbac.PolicyRule {
+		return rbac.PolicyRule{APIGroups: []string{group}, Resources: resources, Verbs: verbs}
+	}
+	return &rbac.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: ccmName},
+		Rules: []rbac.PolicyRule{
+			rule("", []string{"endpoints"}, "get", "watch", "list", "update", "create"),
+			rule("coordination.k8s.io", []string{"leases"}, "get", "watch", "list", "update", "create"),
+			rule("", []string{"nodes", "nodes/status"}, "get", "watch", "list", "update", "delete", "patch"),
+			rule("", []string{"events"}, "get", "watch", "list", "update", "create", "patch"),
+			rule("", []string{"persistentvolumes"}, "get", "watch", "list", "update"),
+			rule("", []string{"secrets", "configmaps"}, "get", "watch", "list"),
+			rule("", []string{"services"}, "get", "watch", "list"),
+			rule("", []string{"services/status"}, "get", "watch", "list", "update", "patch"),
+		},
+	}
+}
+
+// daemonSet follows the upstream ccm-linode manifest.
+func (c *CCMInstaller) daemonSet() *apps.DaemonSet {
+	labels := map[string]string{"app": ccmName}
+	secretEnv := func(name, key string) core.EnvVar {
+		return core.EnvVar{Name: name, ValueFrom: &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+			LocalObjectReference: core.LocalObjectReference{Name: ccmName},
+			Key:                  key,
+		}}}
+	}
+
+	return &apps.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: ccmName, Namespace: ccmNamespace, Labels: labels},
+		Spec: apps.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: core.PodSpec{
+					ServiceAccountName: ccmName,
+					HostNetwork:        true,
+					// Unlike upstream, no control plane node selector: LKE
+					// and terraform clusters have no schedulable control
+					// plane, so the CCM runs on the workers and leader
+					// election keeps one instance active, see verifyLeader.
+					Tolerations: []core.Toleration{{Operator: core.TolerationOpExists}},
+					Containers: []core.Container{{
+						Name:            ccmName,
+						Image:           c.Image,
+						ImagePullPolicy: pullPolicy(c.Image),
+						Args: []string{
+							"--leader-elect-resource-lock=leases",
+							"--v=3",
+							"--secure-port=10253",
+						},
+						Env: []core.EnvVar{
+							secretEnv("LINODE_API_TOKEN", ccmTokenKey),
+							secretEnv("LINODE_REGION", ccmRegionKey),
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// ccmRolledOut is daemonSetRolledOut, except that a DaemonSet no node can run
+// is an error rather than trivially rolled out.
+func ccmRolledOut(ds *apps.DaemonSet) (bool, error) {
+	if ds != nil && ds.Status.ObservedGeneration >= ds.Generation && ds.Status.DesiredNumberScheduled == 0 {
+		return false, errors.Errorf("DaemonSet %s/%s is not scheduled on any node", ds.Namespace, ds.Name)
+	}
+	return daemonSetRolledOut(ds)
+}
+
+func (c *CCMInstaller) track(kind, namespace, name string, delete func() error) {
+	c.created = append(c.created, TrackedResource{Kind: kind, Namespace: namespace, Name: name, delete: delete})
+}
+
+// verifyImage waits until every CCM pod runs the image and returns its
+// digest. When the image is pinned by digest the pods must run exactly that.
+func (c *CCMInstaller) verifyImage(ctx context.Context, selector string) (string, error) {
+	var digest string
+	var lastErr error
+	err := poll("ccm-image", c.interval, c.timeout, func() (bool, error) {
+		pods, err := c.kubeClient.CoreV1().Pods(ccmNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		digest, lastErr = c.runningDigest(pods.Items)
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(lastErr, "verifying the CCM runs %s", c.Image)
+	}
+	glog.Infof("CCM runs %s (%s)", c.Image, digest)
+	return digest, nil
+}
+
+// verifyLeader waits until one of the CCM pods holds the leader election
+// lease. With host networking the holder identity starts with the node name.
+func (c *CCMInstaller) verifyLeader(ctx context.Context, selector string) error {
+	var lastErr error
+	err := poll("ccm-leader", c.interval, c.timeout, func() (bool, error) {
+		lease, err := c.kubeClient.CoordinationV1().Leases(ccmNamespace).Get(ctx, ccmLeaseName, metav1.GetOptions{})
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		pods, err := c.kubeClient.CoreV1().Pods(ccmNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		holder := ""
+		if lease.Spec.HolderIdentity != nil {
+			holder = *lease.Spec.HolderIdentity
+		}
+		for _, pod := range pods.Items {
+			if pod.Spec.NodeName != "" && strings.HasPrefix(holder, pod.Spec.NodeName+"_") {
+				return true, nil
+			}
+		}
+		lastErr = errors.Errorf("lease %s/%s is held by %q, not by a CCM pod running %s; is another CCM running, e.g. the one LKE manages?", ccmNamespace, ccmLeaseName, holder, c.Image)
+		return false, nil
+	})
+	if err != nil {
+		return errors.Wrap(lastErr, "verifying the CCM is the leader")
+	}
+	return nil
+}
+
+func (c *CCMInstaller) runningDigest(pods []core.Pod) (string, error) {
+	if len(pods) == 0 {
+		return "", errors.New("no CCM pods")
+	}
+	digest := ""
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			return "", errors.Errorf("pod %s is still terminating", pod.Name)
+		}
+		containers := map[string]bool{}
+		for _, container := range pod.Spec.Containers {
+			if container.Image == c.Image {
+				containers[container.Name] = true
+			}
+		}
+		if len(containers) == 0 {
+			return "", errors.Errorf("pod %s does not run %s", pod.Name, c.Image)
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if !containers[status.Name] {
+				continue
+			}
+			d := imageDigest(status.ImageID)
+			switch {
+			case d == "":
+				return "", errors.Errorf("pod %s has no image digest yet", pod.Name)
+			case digest != "" && d != digest:
+				return "", errors.Errorf("CCM pods run different digests %s and %s", digest, d)
+			}
+			digest = d
+		}
+	}
+	if digest == "" {
+		return "", errors.New("CCM containers are not running yet")
+	}
+	if pinned := imageDigest(c.Image); pinned != "" && pinned != digest {
+		return "", errors.Errorf("CCM runs digest %s, expected %s", digest, pinned)
+	}
+	return digest, nil
+}
+
+// imageDigest returns the sha256:... part of an image reference or image ID.
+func imageDigest(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	return ""
+}
+
+func pullPolicy(image string) core.PullPolicy {
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if imageDigest(image) != "" || (strings.Contains(name, ":") && !strings.HasSuffix(name, ":latest")) {
+		return core.PullIfNotPresent
+	}
+	return core.PullAlways
+}
@@ -0,0 +1,185 @@
+package framework
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apps "k8s.io/api/apps/v1"
+	coordination "k8s.io/api/coordination/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("CCMInstaller", func() {
+	const (
+		oldImage = "linode/linode-cloud-controller-manager:v0.3.0"
+		newImage = "registry.example.com/ccm:pr-42"
+		digest   = "sha256:0123abcd"
+	)
+	ctx := context.TODO()
+	labels := map[string]string{"app": ccmName}
+
+	ccmPod := func(image, imageID string) *core.Pod {
+		return &core.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "ccm-linode-x1", Namespace: ccmNamespace, Labels: labels},
+			Spec:       core.PodSpec{NodeName: "node-1", Containers: []core.Container{{Name: ccmName, Image: image}}},
+			Status: core.PodStatus{ContainerStatuses: []core.ContainerStatus{{
+				Name:    ccmName,
+				Image:   "docker.io/" + image,
+				ImageID: "docker-pullable://" + image + "@" + imageID,
+			}}},
+		}
+	}
+
+	existing := func() []runtime.Object {
+		return []runtime.Object{
+			&apps.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: ccmName, Namespace: ccmNamespace, Labels: labels},
+				Spec: apps.DaemonSetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: core.PodTemplateSpec{Spec: core.PodSpec{Containers: []core.Container{{Name: ccmName, Image: oldImage}}}},
+				},
+			},
+			&core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: ccmName, Namespace: ccmNamespace},
+				Data:       map[string][]byte{ccmTokenKey: []byte("old-token"), ccmRegionKey: []byte("us-east")},
+			},
+		}
+	}
+
+	lease := func(holder string) *coordination.Lease {
+		return &coordination.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: ccmLeaseName, Namespace: ccmNamespace},
+			Spec:       coordination.LeaseSpec{HolderIdentity: &holder},
+		}
+	}
+
+	// scheduleOn reports the CCM DaemonSet as rolled out on that many nodes,
+	// the fake clientset runs no DaemonSet controller.
+	scheduleOn := func(f *Framework, nodes int32) {
+		client := f.kubeClient.(*fake.Clientset)
+		for _, verb := range []string{"create", "update"} {
+			client.PrependReactor(verb, "daemonsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				ds := action.(k8stesting.CreateAction).GetObject().(*apps.DaemonSet)
+				ds.Status = apps.DaemonSetStatus{
+					ObservedGeneration:     ds.Generation,
+					DesiredNumberScheduled: nodes,
+					UpdatedNumberScheduled: nodes,
+					NumberAvailable:        nodes,
+				}
+				return false, nil, nil
+			})
+		}
+	}
+
+	It("patches an existing DaemonSet and restores it", func() {
+		f := newFakeFramework(append(existing(), ccmPod(newImage, digest))...)
+		scheduleOn(f, 1)
+		installer := f.NewCCMInstaller(newImage, "new-token", "eu-west")
+
+		running, err := installer.Install(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(running).To(Equal(digest))
+
+		ds, err := f.kubeClient.AppsV1().DaemonSets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.Containers[0].Image).To(Equal(newImage))
+		Expect(ds.Spec.Template.Spec.Containers[0].ImagePullPolicy).To(Equal(core.PullIfNotPresent))
+		secret, err := f.kubeClient.CoreV1().Secrets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(secret.Data[ccmTokenKey])).To(Equal("new-token"))
+
+		Expect(installer.Restore(ctx)).To(Succeed())
+
+		ds, err = f.kubeClient.AppsV1().DaemonSets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.Containers[0].Image).To(Equal(oldImage))
+		secret, err = f.kubeClient.CoreV1().Secrets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(secret.Data[ccmTokenKey])).To(Equal("old-token"))
+	})
+
+	It("creates the CCM when the cluster has none and deletes it again", func() {
+		f := newFakeFramework(ccmPod(newImage, digest), lease("node-1_6f2c"))
+		scheduleOn(f, 2)
+		installer := f.NewCCMInstaller(newImage, "token", "eu-west")
+
+		_, err := installer.Install(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		ds, err := f.kubeClient.AppsV1().DaemonSets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.ServiceAccountName).To(Equal(ccmName))
+		Expect(ds.Spec.Template.Spec.NodeSelector).To(BeEmpty())
+		Expect(ds.Spec.Template.Spec.Containers[0].Env[0].ValueFrom.SecretKeyRef.Key).To(Equal(ccmTokenKey))
+		secret, err := f.kubeClient.CoreV1().Secrets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(HaveKeyWithValue(ccmRegionKey, "eu-west"))
+		binding, err := f.kubeClient.RbacV1().ClusterRoleBindings().Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding.RoleRef.Name).To(Equal(ccmName))
+
+		Expect(installer.Restore(ctx)).To(Succeed())
+
+		_, err = f.kubeClient.AppsV1().DaemonSets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(kerr.IsNotFound(err)).To(BeTrue())
+		_, err = f.kubeClient.RbacV1().ClusterRoleBindings().Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(kerr.IsNotFound(err)).To(BeTrue())
+		_, err = f.kubeClient.RbacV1().ClusterRoles().Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(kerr.IsNotFound(err)).To(BeTrue())
+		_, err = f.kubeClient.CoreV1().Secrets(ccmNamespace).Get(ctx, ccmName, metav1.GetOptions{})
+		Expect(kerr.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("fails when another CCM holds the leader lease", func() {
+		f := newFakeFramework(ccmPod(newImage, digest), lease("lke-control-plane_91ab"))
+		scheduleOn(f, 2)
+		installer := f.NewCCMInstaller(newImage, "token", "eu-west")
+
+		_, err := installer.Install(ctx)
+		Expect(err).To(MatchError(ContainSubstring(`is held by "lke-control-plane_91ab"`)))
+	})
+
+	It("fails when no node can run the CCM", func() {
+		f := newFakeFramework(ccmPod(newImage, digest))
+		scheduleOn(f, 0)
+		installer := f.NewCCMInstaller(newImage, "token", "eu-west")
+
+		_, err := installer.Install(ctx)
+		Expect(err).To(MatchError(ContainSubstring("is not scheduled on any node")))
+	})
+
+	It("fails when the pods do not run the pinned digest", func() {
+		f := newFakeFramework(append(existing(), ccmPod(newImage+"@sha256:ffff", digest))...)
+		scheduleOn(f, 1)
+		installer := f.NewCCMInstaller(newImage+"@sha256:ffff", "", "")
+
+		_, err := installer.Install(ctx)
+		Expect(err).To(MatchError(ContainSubstring("expected sha256:ffff")))
+	})
+
+	It("fails when the pods still run the old image", func() {
+		f := newFakeFramework(append(existing(), ccmPod(oldImage, digest))...)
+		scheduleOn(f, 1)
+		installer := f.NewCCMInstaller(newImage, "", "")
+
+		_, err := installer.Install(ctx)
+		Expect(err).To(MatchError(ContainSubstring("does not run " + newImage)))
+	})
+
+	DescribeTable("pull policy",
+		func(image string, policy core.PullPolicy) {
+			Expect(pullPolicy(image)).To(Equal(policy))
+		},
+		Entry("untagged", "linode/ccm", core.PullAlways),
+		Entry("latest", "linode/ccm:latest", core.PullAlways),
+		Entry("registry with port", "localhost:5000/ccm", core.PullAlways),
+		Entry("tagged", "localhost:5000/ccm:v1", core.PullIfNotPresent),
+		Entry("digest", "linode/ccm@sha256:abcd", core.PullIfNotPresent),
+	)
+})
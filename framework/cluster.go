@@ -1,9 +1,34 @@
 package framework
 
-func CreateCluster(cluster string) error {
-	return RunScript("create_cluster.sh", ApiToken, cluster)
-}
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	ProviderLKE       = "lke"
+	ProviderTerraform = "terraform"
+	ProviderKind      = "kind"
+	ProviderExisting  = "existing"
+)
+
+var (
+	ClusterProvider = ProviderLKE
+)
 
-func DeleteCluster() error {
-	return RunScript("delete_cluster.sh")
+// NewClusterProvisioner returns the ClusterProvisioner registered for provider.
+// kubeconfig is only used by the "existing" provider.
+func NewClusterProvisioner(provider, kubeconfig string) (ClusterProvisioner, error) {
+	switch provider {
+	case ProviderLKE:
+		return NewLKEProvisioner(ApiToken), nil
+	case ProviderTerraform:
+		return NewTerraformProvisioner(ApiToken), nil
+	case ProviderKind:
+		return NewKindProvisioner(), nil
+	case ProviderExisting:
+		return NewExistingProvisioner(kubeconfig), nil
+	default:
+		return nil, errors.Errorf("unknown cluster provider %q, expected one of %s, %s, %s or %s",
+			provider, ProviderLKE, ProviderTerraform, ProviderKind, ProviderExisting)
+	}
 }
@@ -0,0 +1,93 @@
+package framework
+
+import (
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// ClusterSpec describes the shape of the cluster a ClusterProvisioner creates.
+type ClusterSpec struct {
+	Name              string         `json:"name"`
+	Region            string         `json:"region"`
+	KubernetesVersion string         `json:"kubernetesVersion,omitempty"`
+	NodePools         []NodePoolSpec `json:"nodePools"`
+	Tags              []string       `json:"tags,omitempty"`
+	HighAvailability  bool           `json:"highAvailability,omitempty"`
+}
+
+type NodePoolSpec struct {
+	Type       string          `json:"type"`
+	Count      int             `json:"count"`
+	Autoscaler *AutoscalerSpec `json:"autoscaler,omitempty"`
+}
+
+type AutoscalerSpec struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// DefaultClusterSpec returns the shape the suite has always used: two
+// g6-standard-2 workers in eu-west on the latest Kubernetes version.
+func DefaultClusterSpec() ClusterSpec {
+	return ClusterSpec{
+		Region: "eu-west",
+		NodePools: []NodePoolSpec{
+			{Type: "g6-standard-2", Count: 2},
+		},
+	}
+}
+
+// LoadClusterSpec reads a YAML or JSON ClusterSpec from path. Fields missing
+// from the file keep their DefaultClusterSpec value.
+func LoadClusterSpec(path string) (ClusterSpec, error) {
+	spec := DefaultClusterSpec()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return spec, errors.Wrapf(err, "parsing cluster spec %s", path)
+	}
+	return spec, nil
+}
+
+// NodeCount returns the number of nodes the spec starts with.
+func (s ClusterSpec) NodeCount() int {
+	count := 0
+	for _, pool := range s.NodePools {
+		count += pool.Count
+	}
+	return count
+}
+
+func (s ClusterSpec) Validate() error {
+	if s.Name == "" {
+		return errors.New("cluster spec has no name")
+	}
+	if s.Region == "" {
+		return errors.New("cluster spec has no region")
+	}
+	if len(s.NodePools) == 0 {
+		return errors.New("cluster spec has no node pools")
+	}
+	for i, pool := range s.NodePools {
+		if pool.Type == "" {
+			return errors.Errorf("node pool %d has no type", i)
+		}
+		if pool.Count < 1 {
+			return errors.Errorf("node pool %d needs at least one node, got %d", i, pool.Count)
+		}
+		if a := pool.Autoscaler; a != nil {
+			if a.Min < 1 || a.Min > a.Max {
+				return errors.Errorf("node pool %d has invalid autoscaler bounds %d-%d", i, a.Min, a.Max)
+			}
+			if pool.Count < a.Min || pool.Count > a.Max {
+				return errors.Errorf("node pool %d count %d is outside autoscaler bounds %d-%d", i, pool.Count, a.Min, a.Max)
+			}
+		}
+	}
+	return nil
+}
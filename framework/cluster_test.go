@@ -0,0 +1,100 @@
+package framework
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewClusterProvisioner", func() {
+	It("returns the provisioner registered for each provider", func() {
+		for provider, expected := range map[string]ClusterProvisioner{
+			ProviderLKE:       &LKEProvisioner{},
+			ProviderTerraform: &TerraformProvisioner{},
+			ProviderKind:      &KindProvisioner{},
+			ProviderExisting:  &ExistingProvisioner{},
+		} {
+			p, err := NewClusterProvisioner(provider, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(BeAssignableToTypeOf(expected))
+		}
+	})
+
+	It("rejects unknown providers", func() {
+		_, err := NewClusterProvisioner("gke", "")
+		Expect(err).To(MatchError(ContainSubstring(`unknown cluster provider "gke"`)))
+	})
+
+	It("uses an existing kubeconfig without creating or deleting anything", func() {
+		kubeconfig := filepath.Join(GinkgoT().TempDir(), "config")
+		p, err := NewClusterProvisioner(ProviderExisting, kubeconfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(p.Create(context.TODO(), ClusterSpec{})).To(HaveOccurred())
+
+		Expect(ioutil.WriteFile(kubeconfig, []byte("apiVersion: v1"), 0600)).To(Succeed())
+		Expect(p.Create(context.TODO(), ClusterSpec{})).To(Succeed())
+		Expect(p.Kubeconfig(context.TODO())).To(Equal(kubeconfig))
+		Expect(p.Delete(context.TODO())).To(Succeed())
+		Expect(kubeconfig).To(BeAnExistingFile())
+	})
+})
+
+var _ = Describe("ClusterSpec", func() {
+	It("loads a spec file on top of the defaults", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "cluster.yaml")
+		Expect(ioutil.WriteFile(path, []byte(`
+name: matrix
+kubernetesVersion: "1.26"
+nodePools:
+- type: g6-standard-4
+  count: 3
+  autoscaler:
+    min: 3
+    max: 5
+- type: g6-dedicated-2
+  count: 1
+highAvailability: true
+`), 0600)).To(Succeed())
+
+		spec, err := LoadClusterSpec(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Region).To(Equal("eu-west"))
+		Expect(spec.KubernetesVersion).To(Equal("1.26"))
+		Expect(spec.NodePools).To(HaveLen(2))
+		Expect(spec.NodePools[0].Autoscaler).To(Equal(&AutoscalerSpec{Min: 3, Max: 5}))
+		Expect(spec.NodeCount()).To(Equal(4))
+		Expect(spec.HighAvailability).To(BeTrue())
+		Expect(spec.Validate()).To(Succeed())
+	})
+
+	It("rejects unknown fields", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "cluster.yaml")
+		Expect(ioutil.WriteFile(path, []byte("regoin: us-east\n"), 0600)).To(Succeed())
+
+		_, err := LoadClusterSpec(path)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("Validate",
+		func(mutate func(*ClusterSpec), expected string) {
+			spec := DefaultClusterSpec()
+			spec.Name = "e2e"
+			mutate(&spec)
+			Expect(spec.Validate()).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("without name", func(s *ClusterSpec) { s.Name = "" }, "no name"),
+		Entry("without region", func(s *ClusterSpec) { s.Region = "" }, "no region"),
+		Entry("without node pools", func(s *ClusterSpec) { s.NodePools = nil }, "no node pools"),
+		Entry("with an empty pool", func(s *ClusterSpec) { s.NodePools[0].Count = 0 }, "at least one node"),
+		Entry("with inverted autoscaler bounds", func(s *ClusterSpec) {
+			s.NodePools[0].Autoscaler = &AutoscalerSpec{Min: 3, Max: 1}
+		}, "invalid autoscaler bounds"),
+		Entry("with count outside autoscaler bounds", func(s *ClusterSpec) {
+			s.NodePools[0].Autoscaler = &AutoscalerSpec{Min: 3, Max: 5}
+		}, "outside autoscaler bounds"),
+	)
+})
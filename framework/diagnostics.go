@@ -0,0 +1,340 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/onsi/ginkgo/v2"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// ArtifactsDir is where diagnostics of failed specs are written, one
+	// directory per spec. Diagnostics are disabled when it is empty.
+	ArtifactsDir = "artifacts"
+)
+
+const (
+	ccmNamespace     = "kube-system"
+	ccmLabelSelector = "app=ccm-linode"
+)
+
+// diagnosticsDir returns the directory for the diagnostics of a spec.
+func diagnosticsDir(report ginkgo.SpecReport) string {
+	return filepath.Join(ArtifactsDir, fmt.Sprintf("%s-%d", labelValue(report.FullText()), report.LeafNodeLocation.LineNumber))
+}
+
+// collectFailedSpecDiagnostics is registered by Invoke. It runs before the
+// objects of the spec are deleted and dumps them when the spec failed.
+// ReportAfterEach nodes run too late for that, after DeferCleanup.
+func (f *Framework) collectFailedSpecDiagnostics(namespace string) {
+	report := ginkgo.CurrentSpecReport()
+	if ArtifactsDir == "" || !report.Failed() {
+		return
+	}
+	dir := diagnosticsDir(report)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		glog.Warningf("Collecting diagnostics into %s: %v", dir, err)
+		return
+	}
+	if err := f.collectNamespaceDiagnostics(context.TODO(), namespace, dir); err != nil {
+		glog.Warningf("Collecting diagnostics into %s: %v", dir, err)
+	}
+}
+
+// ReportDiagnostics is meant to be registered with ReportAfterEach. For failed
+// specs it adds the cluster wide state (nodes, CCM logs) to the diagnostics
+// the Invocation collected and prints where they are.
+func (f *Framework) ReportDiagnostics(report ginkgo.SpecReport) {
+	if ArtifactsDir == "" || !report.Failed() {
+		return
+	}
+	dir := diagnosticsDir(report)
+	if err := f.collectClusterDiagnostics(context.TODO(), dir); err != nil {
+		glog.Warningf("Collecting diagnostics into %s: %v", dir, err)
+	}
+	fmt.Fprintf(ginkgo.GinkgoWriter, "Diagnostics of %q written to %s\n", report.FullText(), dir)
+}
+
+// CollectDiagnostics dumps the pods (status, events, current and previous
+// container logs), services, endpoints and network policies of namespace,
+// the node conditions and the CCM logs into dir. It keeps going when a part
+// fails and returns all errors.
+func (f *Framework) CollectDiagnostics(namespace, dir string) error {
+	ctx := context.TODO()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	errs := []error{
+		f.collectNamespaceDiagnostics(ctx, namespace, dir),
+		f.collectClusterDiagnostics(ctx, dir),
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (f *Framework) collectNamespaceDiagnostics(ctx context.Context, namespace, dir string) error {
+	var errs []error
+	appendErr := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	pods, err := f.kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		appendErr(err)
+	} else {
+		appendErr(writeFile(filepath.Join(dir, "pods.txt"), func(w io.Writer) { printPods(w, pods.Items) }))
+		appendErr(writeYAML(filepath.Join(dir, "pods.yaml"), pods))
+		appendErr(f.collectLogs(ctx, pods.Items, filepath.Join(dir, "logs")))
+	}
+
+	events, err := f.kubeClient.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		appendErr(err)
+	} else {
+		appendErr(writeFile(filepath.Join(dir, "events.txt"), func(w io.Writer) { printEvents(w, events.Items) }))
+	}
+
+	services, err := f.kubeClient.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		appendErr(err)
+	} else {
+		appendErr(writeFile(filepath.Join(dir, "services.txt"), func(w io.Writer) { printServices(w, services.Items) }))
+		appendErr(writeYAML(filepath.Join(dir, "services.yaml"), services))
+	}
+
+	endpoints, err := f.kubeClient.CoreV1().Endpoints(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		appendErr(err)
+	} else {
+		appendErr(writeYAML(filepath.Join(dir, "endpoints.yaml"), endpoints))
+	}
+
+	policies, err := f.kubeClient.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		appendErr(err)
+	} else {
+		appendErr(writeYAML(filepath.Join(dir, "networkpolicies.yaml"), policies))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (f *Framework) collectClusterDiagnostics(ctx context.Context, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []error
+	nodes, err := f.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, err)
+	} else if err := writeFile(filepath.Join(dir, "nodes.txt"), func(w io.Writer) { printNodes(w, nodes.Items) }); err != nil {
+		errs = append(errs, err)
+	}
+
+	ccmPods, err := f.kubeClient.CoreV1().Pods(ccmNamespace).List(ctx, metav1.ListOptions{LabelSelector: ccmLabelSelector})
+	if err != nil {
+		errs = append(errs, err)
+	} else if err := f.collectLogs(ctx, ccmPods.Items, filepath.Join(dir, "ccm")); err != nil {
+		errs = append(errs, err)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// collectLogs writes the logs of every container of pods into dir, including
+// the logs of the previous instance of containers that restarted.
+func (f *Framework) collectLogs(ctx context.Context, pods []core.Pod, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pod := range pods {
+		restarts := map[string]int32{}
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts[status.Name] = status.RestartCount
+		}
+		for _, container := range pod.Spec.Containers {
+			name := fmt.Sprintf("%s-%s", pod.Name, container.Name)
+			if err := f.writeLogs(ctx, pod, container.Name, false, filepath.Join(dir, name+".log")); err != nil {
+				errs = append(errs, err)
+			}
+			if restarts[container.Name] == 0 {
+				continue
+			}
+			if err := f.writeLogs(ctx, pod, container.Name, true, filepath.Join(dir, name+".previous.log")); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (f *Framework) writeLogs(ctx context.Context, pod core.Pod, container string, previous bool, path string) error {
+	stream, err := f.kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &core.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+	}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, stream)
+	return err
+}
+
+func writeYAML(path string, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func writeFile(path string, print func(w io.Writer)) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	print(w)
+	return w.Flush()
+}
+
+// sprintTable returns the table print writes, for error messages.
+func sprintTable(print func(w io.Writer)) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	print(w)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// maxDescribedEvents caps the events describeEvents lists.
+const maxDescribedEvents = 10
+
+// describeEvents lists the most recent events of an object for an error
+// message.
+func (f *Framework) describeEvents(namespace, kind, name string) string {
+	events, err := f.kubeClient.CoreV1().Events(namespace).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.String(),
+	})
+	if err != nil {
+		return fmt.Sprintf("listing events of %s %s/%s: %v", kind, namespace, name, err)
+	}
+	var items []core.Event
+	for _, e := range events.Items {
+		// Not every client honours the field selector.
+		if e.InvolvedObject.Kind == kind && e.InvolvedObject.Name == name {
+			items = append(items, e)
+		}
+	}
+	if len(items) == 0 {
+		return fmt.Sprintf("no events for %s %s/%s", kind, namespace, name)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(items[i]).Before(eventTime(items[j]))
+	})
+	if len(items) > maxDescribedEvents {
+		items = items[len(items)-maxDescribedEvents:]
+	}
+	return sprintTable(func(w io.Writer) { printEvents(w, items) })
+}
+
+func printPods(w io.Writer, pods []core.Pod) {
+	fmt.Fprintln(w, "NAME\tPHASE\tREADY\tRESTARTS\tNODE\tREASON")
+	for _, pod := range pods {
+		ready, restarts, reasons := 0, int32(0), []string{}
+		if pod.Status.Reason != "" {
+			reasons = append(reasons, pod.Status.Reason)
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+			restarts += status.RestartCount
+			if status.State.Waiting != nil {
+				reasons = append(reasons, status.State.Waiting.Reason)
+			}
+			if status.State.Terminated != nil {
+				reasons = append(reasons, status.State.Terminated.Reason)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%s\t%s\n", pod.Name, pod.Status.Phase, ready, len(pod.Spec.Containers),
+			restarts, pod.Spec.NodeName, strings.Join(reasons, ","))
+	}
+}
+
+func printEvents(w io.Writer, events []core.Event) {
+	sort.Slice(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	fmt.Fprintln(w, "LAST SEEN\tTYPE\tREASON\tOBJECT\tCOUNT\tMESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s/%s\t%d\t%s\n", eventTime(e).UTC().Format("15:04:05"), e.Type, e.Reason,
+			strings.ToLower(e.InvolvedObject.Kind), e.InvolvedObject.Name, e.Count, e.Message)
+	}
+}
+
+func eventTime(e core.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+func printServices(w io.Writer, services []core.Service) {
+	fmt.Fprintln(w, "NAME\tTYPE\tCLUSTER-IP\tEXTERNAL-IP\tPORTS")
+	for _, svc := range services {
+		var ingress, ports []string
+		for _, i := range svc.Status.LoadBalancer.Ingress {
+			ingress = append(ingress, i.IP+i.Hostname)
+		}
+		for _, p := range svc.Spec.Ports {
+			ports = append(ports, fmt.Sprintf("%d:%d/%s", p.Port, p.NodePort, p.Protocol))
+		}
+		external := strings.Join(ingress, ",")
+		if external == "" && svc.Spec.Type == core.ServiceTypeLoadBalancer {
+			external = "<pending>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", svc.Name, svc.Spec.Type, svc.Spec.ClusterIP, external, strings.Join(ports, ","))
+	}
+}
+
+func printNodes(w io.Writer, nodes []core.Node) {
+	fmt.Fprintln(w, "NAME\tCONDITION\tSTATUS\tREASON\tMESSAGE")
+	for _, node := range nodes {
+		for _, cond := range node.Status.Conditions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", node.Name, cond.Type, cond.Status, cond.Reason, cond.Message)
+		}
+	}
+}
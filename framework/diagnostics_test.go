@@ -0,0 +1,73 @@
+package framework
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("CollectDiagnostics", func() {
+	It("dumps the namespace and cluster state", func() {
+		f := newFakeFramework()
+		ns := f.Namespace()
+		f = newFakeFramework(
+			&core.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: ns},
+				Spec:       core.PodSpec{NodeName: "node-1", Containers: []core.Container{{Name: "app"}}},
+				Status: core.PodStatus{
+					Phase: core.PodRunning,
+					ContainerStatuses: []core.ContainerStatus{{
+						Name:         "app",
+						RestartCount: 3,
+						State:        core.ContainerState{Waiting: &core.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					}},
+				},
+			},
+			&core.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: "backend.1", Namespace: ns},
+				InvolvedObject: core.ObjectReference{Kind: "Pod", Name: "backend"},
+				Type:           core.EventTypeWarning,
+				Reason:         "BackOff",
+				Message:        "Back-off restarting failed container",
+			},
+			&core.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+				Spec:       core.ServiceSpec{Type: core.ServiceTypeLoadBalancer, Ports: []core.ServicePort{{Port: 80, NodePort: 30080, Protocol: core.ProtocolTCP}}},
+			},
+			&core.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				Status: core.NodeStatus{Conditions: []core.NodeCondition{
+					{Type: core.NodeReady, Status: core.ConditionFalse, Reason: "KubeletNotReady"},
+				}},
+			},
+			&core.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "ccm-linode-abc", Namespace: "kube-system", Labels: map[string]string{"app": "ccm-linode"}},
+				Spec:       core.PodSpec{Containers: []core.Container{{Name: "ccm-linode"}}},
+			},
+		)
+		f.namespace = ns
+		dir := GinkgoT().TempDir()
+
+		Expect(f.CollectDiagnostics(ns, dir)).To(Succeed())
+
+		read := func(name string) string {
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			Expect(err).NotTo(HaveOccurred())
+			return string(data)
+		}
+		Expect(read("pods.txt")).To(MatchRegexp(`backend\s+Running\s+0/1\s+3\s+node-1\s+CrashLoopBackOff`))
+		Expect(read("pods.yaml")).To(ContainSubstring("name: backend"))
+		Expect(read("logs/backend-app.log")).To(Equal("fake logs"))
+		Expect(read("logs/backend-app.previous.log")).To(Equal("fake logs"))
+		Expect(read("events.txt")).To(ContainSubstring("Back-off restarting failed container"))
+		Expect(read("services.txt")).To(MatchRegexp(`hello\s+LoadBalancer\s+<pending>\s+80:30080/TCP`))
+		Expect(filepath.Join(dir, "endpoints.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "networkpolicies.yaml")).To(BeAnExistingFile())
+		Expect(read("nodes.txt")).To(MatchRegexp(`node-1\s+Ready\s+False\s+KubeletNotReady`))
+		Expect(read("ccm/ccm-linode-abc-ccm-linode.log")).To(Equal("fake logs"))
+	})
+})
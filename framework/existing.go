@@ -0,0 +1,39 @@
+package framework
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ExistingProvisioner uses a cluster that was created outside of the suite.
+// It never creates or deletes anything.
+type ExistingProvisioner struct {
+	kubeconfig string
+}
+
+var _ ClusterProvisioner = &ExistingProvisioner{}
+
+func NewExistingProvisioner(kubeconfig string) *ExistingProvisioner {
+	return &ExistingProvisioner{kubeconfig: kubeconfig}
+}
+
+func (p *ExistingProvisioner) Create(ctx context.Context, spec ClusterSpec) error {
+	if _, err := os.Stat(p.kubeconfig); err != nil {
+		return errors.Wrap(err, "existing cluster needs a readable kubeconfig")
+	}
+	return nil
+}
+
+func (p *ExistingProvisioner) Kubeconfig(ctx context.Context) (string, error) {
+	return p.kubeconfig, nil
+}
+
+func (p *ExistingProvisioner) Delete(ctx context.Context) error {
+	return nil
+}
+
+func (p *ExistingProvisioner) Describe() string {
+	return "existing cluster from " + p.kubeconfig
+}
@@ -0,0 +1,344 @@
+package framework
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeLinodeAPI is an in-memory stand-in for the parts of the Linode API v4
+// used by the framework.
+type fakeLinodeAPI struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	nextID        int
+	clusters      map[int]*fakeLKECluster
+	nodeBalancers map[int]*nodeBalancer
+	nbConfigs     map[int][]NodeBalancerConfig
+	volumes       map[int]*volume
+	instances     map[int]*instance
+	versions      []string
+
+	// pollsUntilReady is the number of node pool listings that report
+	// provisioning nodes before they turn ready.
+	pollsUntilReady int
+	// kubeconfigUnavailable is the number of kubeconfig requests answered with 503.
+	kubeconfigUnavailable int
+	// createErrors makes cluster creation fail with the given reasons.
+	createErrors []string
+	// created records the options of every cluster creation request.
+	created []lkeClusterCreateOptions
+}
+
+type fakeLKECluster struct {
+	cluster lkeCluster
+	pools   []lkeNodePool
+	polls   int
+	// nodesFixed serves pools with their stored nodes instead of generating them.
+	nodesFixed bool
+}
+
+func newFakeLinodeAPI() *fakeLinodeAPI {
+	f := &fakeLinodeAPI{
+		nextID:        1,
+		clusters:      map[int]*fakeLKECluster{},
+		nodeBalancers: map[int]*nodeBalancer{},
+		nbConfigs:     map[int][]NodeBalancerConfig{},
+		volumes:       map[int]*volume{},
+		instances:     map[int]*instance{},
+		versions:      []string{"1.25", "1.26", "1.9"},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+func (f *fakeLinodeAPI) client() *linodeClient {
+	return newLinodeClient(f.URL, "fake-token")
+}
+
+// addCluster stores a ready cluster with one node per instance ID.
+func (f *fakeLinodeAPI) addCluster(label string, created time.Time, tags []string, instanceIDs ...int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := &fakeLKECluster{cluster: lkeCluster{
+		ID:      f.nextID,
+		Label:   label,
+		Status:  "ready",
+		Tags:    tags,
+		Created: created.UTC().Format(linodeTimeLayout),
+	}}
+	pool := lkeNodePool{ID: 1, Type: "g6-standard-2", Count: len(instanceIDs)}
+	for i, id := range instanceIDs {
+		pool.Nodes = append(pool.Nodes, lkeNode{ID: fmt.Sprintf("1-%d", i), InstanceID: id, Status: lkeNodeStatusReady})
+	}
+	c.pools = []lkeNodePool{pool}
+	c.nodesFixed = true
+	f.clusters[c.cluster.ID] = c
+	f.nextID++
+	return c.cluster.ID
+}
+
+func (f *fakeLinodeAPI) addNodeBalancer(nb nodeBalancer, created time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	nb.ID = f.nextID
+	nb.Created = created.UTC().Format(linodeTimeLayout)
+	f.nodeBalancers[nb.ID] = &nb
+	f.nextID++
+	return nb.ID
+}
+
+func (f *fakeLinodeAPI) addNodeBalancerConfig(id int, config NodeBalancerConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	config.ID = len(f.nbConfigs[id]) + 1
+	f.nbConfigs[id] = append(f.nbConfigs[id], config)
+}
+
+func (f *fakeLinodeAPI) addVolume(v volume, created time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	v.ID = f.nextID
+	v.Created = created.UTC().Format(linodeTimeLayout)
+	f.volumes[v.ID] = &v
+	f.nextID++
+	return v.ID
+}
+
+func (f *fakeLinodeAPI) addInstance(l instance, created time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l.ID = f.nextID
+	l.Created = created.UTC().Format(linodeTimeLayout)
+	f.instances[l.ID] = &l
+	f.nextID++
+	return l.ID
+}
+
+func (f *fakeLinodeAPI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer fake-token" {
+		writeLinodeError(w, http.StatusUnauthorized, "Invalid Token")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var id int
+	if len(parts) > 1 {
+		id, _ = strconv.Atoi(parts[len(parts)-1])
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/lke/clusters":
+		var data []lkeCluster
+		for _, c := range f.clusters {
+			data = append(data, c.cluster)
+		}
+		writeLinodeJSON(w, map[string]interface{}{"data": data, "page": 1, "pages": 1})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/nodebalancers":
+		var data []nodeBalancer
+		for _, nb := range f.nodeBalancers {
+			data = append(data, *nb)
+		}
+		writeLinodeJSON(w, map[string]interface{}{"data": data, "page": 1, "pages": 1})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/nodebalancers":
+		var opts nodeBalancerCreateOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			writeLinodeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		nb := &nodeBalancer{
+			ID:      f.nextID,
+			Label:   fmt.Sprintf("nodebalancer%d", f.nextID),
+			Region:  opts.Region,
+			IPv4:    fmt.Sprintf("192.0.2.%d", f.nextID),
+			Tags:    opts.Tags,
+			Created: time.Now().UTC().Format(linodeTimeLayout),
+		}
+		f.nextID++
+		f.nodeBalancers[nb.ID] = nb
+		writeLinodeJSON(w, nb)
+
+	case len(parts) >= 2 && parts[0] == "nodebalancers":
+		id, _ = strconv.Atoi(parts[1])
+		nb, ok := f.nodeBalancers[id]
+		if !ok {
+			writeLinodeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			writeLinodeJSON(w, nb)
+		case len(parts) == 2 && r.Method == http.MethodDelete:
+			delete(f.nodeBalancers, id)
+			delete(f.nbConfigs, id)
+			writeLinodeJSON(w, map[string]interface{}{})
+		case len(parts) == 3 && parts[2] == "configs" && r.Method == http.MethodGet:
+			writeLinodeJSON(w, map[string]interface{}{"data": f.nbConfigs[id], "page": 1, "pages": 1})
+		default:
+			writeLinodeError(w, http.StatusNotFound, "Not found")
+		}
+
+	case r.Method == http.MethodGet && r.URL.Path == "/linode/instances":
+		var data []instance
+		for _, l := range f.instances {
+			data = append(data, *l)
+		}
+		writeLinodeJSON(w, map[string]interface{}{"data": data, "page": 1, "pages": 1})
+
+	case r.Method == http.MethodDelete && len(parts) == 3 && parts[0] == "linode" && parts[1] == "instances":
+		if _, ok := f.instances[id]; !ok {
+			writeLinodeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		delete(f.instances, id)
+		writeLinodeJSON(w, map[string]interface{}{})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/volumes":
+		var data []volume
+		for _, v := range f.volumes {
+			data = append(data, *v)
+		}
+		writeLinodeJSON(w, map[string]interface{}{"data": data, "page": 1, "pages": 1})
+
+	case len(parts) == 2 && parts[0] == "volumes":
+		v, ok := f.volumes[id]
+		if !ok {
+			writeLinodeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeLinodeJSON(w, v)
+		case http.MethodPut:
+			var update struct {
+				Tags []string `json:"tags"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				writeLinodeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			v.Tags = update.Tags
+			writeLinodeJSON(w, v)
+		case http.MethodDelete:
+			if v.LinodeID != nil {
+				writeLinodeError(w, http.StatusBadRequest, "Volume must be detached before deletion.")
+				return
+			}
+			delete(f.volumes, id)
+			writeLinodeJSON(w, map[string]interface{}{})
+		default:
+			writeLinodeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+
+	case r.Method == http.MethodGet && r.URL.Path == "/lke/versions":
+		var data []map[string]string
+		for _, v := range f.versions {
+			data = append(data, map[string]string{"id": v})
+		}
+		writeLinodeJSON(w, map[string]interface{}{"data": data, "page": 1, "pages": 1})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/lke/clusters":
+		if len(f.createErrors) > 0 {
+			writeLinodeError(w, http.StatusBadRequest, f.createErrors...)
+			return
+		}
+		var opts lkeClusterCreateOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			writeLinodeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		f.created = append(f.created, opts)
+		c := &fakeLKECluster{cluster: lkeCluster{
+			ID:         f.nextID,
+			Label:      opts.Label,
+			Region:     opts.Region,
+			K8sVersion: opts.K8sVersion,
+			Status:     "ready",
+			Tags:       opts.Tags,
+			Created:    time.Now().UTC().Format(linodeTimeLayout),
+		}}
+		f.nextID++
+		for i, np := range opts.NodePools {
+			c.pools = append(c.pools, lkeNodePool{ID: i + 1, Type: np.Type, Count: np.Count})
+		}
+		f.clusters[c.cluster.ID] = c
+		writeLinodeJSON(w, c.cluster)
+
+	case len(parts) >= 3 && parts[0] == "lke" && parts[1] == "clusters":
+		id, _ = strconv.Atoi(parts[2])
+		c, ok := f.clusters[id]
+		if !ok {
+			writeLinodeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		switch {
+		case len(parts) == 3 && r.Method == http.MethodDelete:
+			delete(f.clusters, id)
+			writeLinodeJSON(w, map[string]interface{}{})
+		case len(parts) == 3 && r.Method == http.MethodGet:
+			writeLinodeJSON(w, c.cluster)
+		case len(parts) == 4 && parts[3] == "pools":
+			c.polls++
+			if c.nodesFixed {
+				writeLinodeJSON(w, map[string]interface{}{"data": c.pools, "page": 1, "pages": 1})
+				return
+			}
+			pools := make([]lkeNodePool, 0, len(c.pools))
+			for _, p := range c.pools {
+				for i := 0; i < p.Count; i++ {
+					status := "not_ready"
+					if c.polls > f.pollsUntilReady {
+						status = lkeNodeStatusReady
+					}
+					p.Nodes = append(p.Nodes, lkeNode{ID: fmt.Sprintf("%d-%d", p.ID, i), InstanceID: 1000*p.ID + i, Status: status})
+				}
+				pools = append(pools, p)
+			}
+			writeLinodeJSON(w, map[string]interface{}{"data": pools, "page": 1, "pages": 1})
+		case len(parts) == 4 && parts[3] == "kubeconfig":
+			if f.kubeconfigUnavailable > 0 {
+				f.kubeconfigUnavailable--
+				writeLinodeError(w, http.StatusServiceUnavailable, "Cluster kubeconfig is not yet available.")
+				return
+			}
+			kubeconfig := base64.StdEncoding.EncodeToString([]byte("kubeconfig-for-" + c.cluster.Label))
+			writeLinodeJSON(w, map[string]string{"kubeconfig": kubeconfig})
+		default:
+			writeLinodeError(w, http.StatusNotFound, "Not found")
+		}
+
+	default:
+		writeLinodeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func writeLinodeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeLinodeError(w http.ResponseWriter, code int, reasons ...string) {
+	var errs []map[string]string
+	for _, r := range reasons {
+		errs = append(errs, map[string]string{"reason": r})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+}
@@ -4,30 +4,57 @@ import (
 	"time"
 
 	"github.com/linode/linode-k8s-e2e-tests/rand"
+	"github.com/onsi/ginkgo/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 var (
-	Image          = "linode/linode-cloud-controller-manager:latest"
-	ApiToken       = ""
+	Image    = "linode/linode-cloud-controller-manager:latest"
+	ApiToken = ""
+	// DockerRegistry hosts the KubeDB images of the catalog, see
+	// UseKubeDBImage.
 	DockerRegistry = "kubedbci"
-	DBCatalogName  = "9.6-v2"
-	StorageClass   = "linode-block-storage"
-	Timeout        time.Duration
-	RetryInterval  time.Duration
+	// DBCatalogName is the PostgresVersion in PostgresCatalog the database
+	// specs run.
+	DBCatalogName = "9.6-v2"
+	StorageClass  = "linode-block-storage"
+	Timeout       time.Duration
+	RetryInterval time.Duration
+	// IsolateNamespaces makes every Invocation run in its own namespace.
+	IsolateNamespaces = false
+	// RunID identifies the current run. Everything the suite creates in the
+	// Linode account is tagged with it and with E2ETag.
+	RunID string
 )
 
 const (
 	frontendImage = "docker.io/linode/hello-frontend:latest"
 	backendImage  = "gcr.io/google-samples/hello-go-gke:1.0"
+
+	// E2ETag marks Linode resources created by the suite, see Sweeper.
+	E2ETag = "linode-k8s-e2e"
 )
 
+// ResourceTags returns the tags for Linode resources created during this run.
+func ResourceTags() []string {
+	if RunID == "" {
+		return []string{E2ETag}
+	}
+	return []string{E2ETag, RunID}
+}
+
 type Framework struct {
 	restConfig    *rest.Config
 	kubeConfig    string
 	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	restMapper    meta.RESTMapper
 	metricsClient *metricsclientset.Clientset
 	namespace     string
 	name          string
@@ -49,14 +76,46 @@ func New(
 		kubeClient:    kubeClient,
 		kubeConfig:    kubeConfig,
 		metricsClient: metricsClient,
+		restMapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(kubeClient.Discovery())),
 		name:          "lke-test",
 		namespace:     suffix,
 	}
+	// Serves the kinds without typed clients, e.g. VolumeSnapshots.
+	if restConfig != nil {
+		dynamicClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		out.dynamicClient = dynamicClient
+	}
 
 	return out, nil
 }
 
-func (f *Framework) Invoke() (*Invocation, error) {
+type invokeOptions struct {
+	isolatedNamespace bool
+}
+
+// InvokeOption customizes the Invocation returned by Framework.Invoke.
+type InvokeOption func(*invokeOptions)
+
+// WithIsolatedNamespace runs the Invocation in a namespace of its own, which is
+// deleted when the current spec ends, regardless of IsolateNamespaces.
+func WithIsolatedNamespace() InvokeOption {
+	return func(o *invokeOptions) {
+		o.isolatedNamespace = true
+	}
+}
+
+// Invoke returns an Invocation for the current spec. It must be called from a
+// setup node such as BeforeEach, since the cleanup of the objects created
+// through the Invocation is registered with DeferCleanup.
+func (f *Framework) Invoke(opts ...InvokeOption) (*Invocation, error) {
+	options := invokeOptions{isolatedNamespace: IsolateNamespaces}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	suffix, errSuffix := rand.WithRandomSuffix("e2e-test")
 	if errSuffix != nil {
 		return nil, errSuffix
@@ -66,8 +125,45 @@ func (f *Framework) Invoke() (*Invocation, error) {
 		Framework:     f,
 		Timeout:       Timeout,
 		RetryInterval: RetryInterval,
+		Timeouts:      OperationTimeouts.withDefault(Timeout),
 		app:           suffix,
+		namespace:     f.namespace,
+		tracker:       &tracker{},
+	}
+
+	if options.isolatedNamespace {
+		ns, err := rand.WithRandomSuffix(f.namespace + "-")
+		if err != nil {
+			return nil, err
+		}
+		err = f.createNamespace(ns, map[string]string{
+			runIDLabel: labelValue(RunID),
+			specLabel:  labelValue(ginkgo.CurrentSpecReport().FullText()),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !SkipCleanup {
+			ginkgo.DeferCleanup(func() error {
+				if keepFailedSpec(ns, nil) {
+					return nil
+				}
+				return f.deleteNamespace(ns)
+			})
+		}
+		r.namespace = ns
+	}
+	if !SkipCleanup {
+		// Runs before the namespace deletion above, DeferCleanup is LIFO.
+		ginkgo.DeferCleanup(func() error {
+			if keepFailedSpec("", r.Resources()) {
+				return nil
+			}
+			return r.Cleanup()
+		})
 	}
+	// Runs first, while the objects of a failed spec still exist.
+	ginkgo.DeferCleanup(f.collectFailedSpecDiagnostics, r.namespace)
 
 	out := &Invocation{
 		rootInvocation: r,
@@ -86,9 +182,27 @@ type rootInvocation struct {
 	*Framework
 	Timeout       time.Duration
 	RetryInterval time.Duration
+	Timeouts      Timeouts
 	app           string
+	namespace     string
+	tracker       *tracker
+}
+
+// Namespace returns the namespace of the invocation, which is the framework
+// namespace unless the invocation is isolated.
+func (r *rootInvocation) Namespace() string {
+	return r.namespace
 }
 
 type k8sInvocation struct {
 	*rootInvocation
 }
+
+// InNamespace returns a copy of the invocation that works in namespace, e.g.
+// to read objects a chart created in kube-system. Objects created through the
+// copy are still deleted when the spec ends.
+func (i *k8sInvocation) InNamespace(namespace string) *k8sInvocation {
+	r := *i.rootInvocation
+	r.namespace = namespace
+	return &k8sInvocation{rootInvocation: &r}
+}
@@ -0,0 +1,19 @@
+package framework
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFramework(t *testing.T) {
+	Timeout = time.Second
+	RetryInterval = 10 * time.Millisecond
+	// Failing unit specs must not dump the fake cluster into the tree.
+	ArtifactsDir = ""
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Framework Suite")
+}
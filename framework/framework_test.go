@@ -0,0 +1,98 @@
+package framework
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeListKinds are the list kinds of the resources the fake dynamic client
+// serves.
+var fakeListKinds = map[schema.GroupVersionResource]string{
+	volumeSnapshotGVR:      "VolumeSnapshotList",
+	volumeSnapshotClassGVR: "VolumeSnapshotClassList",
+	configMapGVR:           "ConfigMapList",
+	namespaceGVR:           "NamespaceList",
+	widgetGVR:              "WidgetList",
+}
+
+// newFakeFramework returns a Framework backed by fake clients in which
+// namespaces become Active as soon as they are created.
+func newFakeFramework(objects ...runtime.Object) *Framework {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("create", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		ns := action.(k8stesting.CreateAction).GetObject().(*core.Namespace)
+		ns.Status.Phase = core.NamespaceActive
+		return false, nil, nil
+	})
+
+	f, err := New(nil, client, "", nil)
+	Expect(err).NotTo(HaveOccurred())
+	f.dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), fakeListKinds)
+	return f
+}
+
+var _ = Describe("Invoke", func() {
+	It("shares the framework namespace by default", func() {
+		f := newFakeFramework()
+
+		inv, err := f.Invoke()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(inv.Namespace()).To(Equal(f.Namespace()))
+		Expect(inv.Cluster.Namespace()).To(Equal(f.Namespace()))
+	})
+
+	Context("with an isolated namespace", Ordered, func() {
+		var (
+			f        *Framework
+			inv      *Invocation
+			previous string
+		)
+
+		BeforeAll(func() {
+			f = newFakeFramework()
+			RunID = "e2e-run-1234"
+			DeferCleanup(func() { RunID = "" })
+		})
+
+		BeforeEach(func() {
+			var err error
+			inv, err = f.Invoke(WithIsolatedNamespace())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("creates a labelled namespace for the spec", func() {
+			Expect(inv.Namespace()).To(HavePrefix(f.Namespace() + "-"))
+			Expect(inv.Cluster.Namespace()).To(Equal(inv.Namespace()))
+
+			ns, err := f.kubeClient.CoreV1().Namespaces().Get(context.TODO(), inv.Namespace(), metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ns.Labels).To(HaveKeyWithValue(runIDLabel, "e2e-run-1234"))
+			Expect(ns.Labels).To(HaveKeyWithValue(specLabel, "Invoke_with_an_isolated_namespace_creates_a_labelled_namespace"))
+			previous = inv.Namespace()
+		})
+
+		It("deletes the namespace when the spec ends", func() {
+			Expect(inv.Namespace()).NotTo(Equal(previous))
+			_, err := f.kubeClient.CoreV1().Namespaces().Get(context.TODO(), previous, metav1.GetOptions{})
+			Expect(kerr.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("labelValue", func() {
+	It("produces valid label values", func() {
+		Expect(labelValue("CloudControllerManager Test [slow] ")).To(Equal("CloudControllerManager_Test_slow"))
+		Expect(len(labelValue(string(make([]byte, 100))))).To(BeNumerically("<=", 63))
+	})
+})
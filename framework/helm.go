@@ -0,0 +1,46 @@
+package framework
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/pkg/errors"
+)
+
+// InstallHelmChart installs chart as release into the invocation namespace,
+// waits up to Timeouts.Helm for its resources to become ready and tracks the
+// release for cleanup. A failed install is rolled back, so it can be retried.
+// args are passed to helm install as they are.
+func (i *k8sInvocation) InstallHelmChart(release, chart string, args ...string) error {
+	args = append([]string{"install", release, chart, "--atomic", "--timeout", i.Timeouts.Helm.String()}, args...)
+	out, err := i.helm(args...)
+	if err != nil {
+		return errors.Wrapf(err, "helm install %s %s: %s", release, chart, out)
+	}
+	i.tracker.track("HelmRelease", i.Namespace(), release, func() error { return i.DeleteHelmChart(release) })
+
+	return nil
+}
+
+// DeleteHelmChart deletes the release. A release that does not exist is
+// already deleted, any other failure, including a missing helm binary, is an
+// error.
+func (i *k8sInvocation) DeleteHelmChart(release string) error {
+	out, err := i.helm("delete", release)
+	if err != nil && strings.Contains(out, "release: not found") {
+		return nil
+	}
+	return errors.Wrapf(err, "helm delete %s: %s", release, out)
+}
+
+func (i *k8sInvocation) helm(args ...string) (string, error) {
+	out, err := exec.Command("helm", i.helmArgs(args...)...).CombinedOutput()
+	fmt.Fprintf(ginkgo.GinkgoWriter, "helm %s\n%s", strings.Join(args, " "), out)
+	return string(out), err
+}
+
+func (i *k8sInvocation) helmArgs(args ...string) []string {
+	return append(args, "--kubeconfig", i.kubeConfig, "--namespace", i.Namespace())
+}
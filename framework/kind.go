@@ -0,0 +1,102 @@
+package framework
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KindProvisioner creates a local cluster with kind. Linode specific specs
+// (LoadBalancers, block storage) are not expected to pass against it.
+type KindProvisioner struct {
+	KubeconfigDir string
+
+	name       string
+	kubeconfig string
+}
+
+var _ ClusterProvisioner = &KindProvisioner{}
+
+func NewKindProvisioner() *KindProvisioner {
+	return &KindProvisioner{}
+}
+
+func (p *KindProvisioner) Create(ctx context.Context, spec ClusterSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	dir := p.KubeconfigDir
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	kubeconfig := filepath.Join(dir, spec.Name+".conf")
+
+	config := filepath.Join(dir, spec.Name+"-kind.yaml")
+	if err := ioutil.WriteFile(config, []byte(kindConfig(spec)), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(config)
+
+	args := []string{"create", "cluster", "--name", spec.Name, "--kubeconfig", kubeconfig, "--config", config, "--wait", "5m"}
+	if spec.KubernetesVersion != "" {
+		// kind node images are tagged with full versions, e.g. 1.25.3.
+		args = append(args, "--image", "kindest/node:v"+strings.TrimPrefix(spec.KubernetesVersion, "v"))
+	}
+	if err := runCommand("kind", args...); err != nil {
+		return err
+	}
+	p.name = spec.Name
+	p.kubeconfig = kubeconfig
+
+	return nil
+}
+
+func (p *KindProvisioner) Kubeconfig(ctx context.Context) (string, error) {
+	if p.kubeconfig == "" {
+		return "", ErrClusterNotCreated
+	}
+	return p.kubeconfig, nil
+}
+
+func (p *KindProvisioner) Delete(ctx context.Context) error {
+	if p.name == "" {
+		return ErrClusterNotCreated
+	}
+	if err := runCommand("kind", "delete", "cluster", "--name", p.name, "--kubeconfig", p.kubeconfig); err != nil {
+		return err
+	}
+	if err := os.Remove(p.kubeconfig); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	p.name = ""
+	p.kubeconfig = ""
+
+	return nil
+}
+
+// kindConfig returns a kind cluster config with one control plane node (three
+// with HA) and one worker per node in spec.
+func kindConfig(spec ClusterSpec) string {
+	var b strings.Builder
+	b.WriteString("kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n- role: control-plane\n")
+	if spec.HighAvailability {
+		b.WriteString("- role: control-plane\n- role: control-plane\n")
+	}
+	for i := 0; i < spec.NodeCount(); i++ {
+		b.WriteString("- role: worker\n")
+	}
+	return b.String()
+}
+
+func (p *KindProvisioner) Describe() string {
+	if p.name != "" {
+		return "kind cluster " + p.name
+	}
+	return "kind"
+}
@@ -0,0 +1,390 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	LinodeURL = "https://api.linode.com/v4"
+)
+
+// APIError is returned when the Linode API answers with a non-2xx status code.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Reasons    []string
+}
+
+func (e *APIError) Error() string {
+	msg := http.StatusText(e.StatusCode)
+	if len(e.Reasons) > 0 {
+		msg = strings.Join(e.Reasons, "; ")
+	}
+	return fmt.Sprintf("linode api %s %s: [%d] %s", e.Method, e.Path, e.StatusCode, msg)
+}
+
+func isAPIStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+type linodeClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newLinodeClient(baseURL, token string) *linodeClient {
+	return &linodeClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  http.DefaultClient,
+	}
+}
+
+func (c *linodeClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{Method: method, Path: path, StatusCode: resp.StatusCode}
+		var errResp struct {
+			Errors []struct {
+				Field  string `json:"field"`
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		}
+		if json.Unmarshal(data, &errResp) == nil {
+			for _, e := range errResp.Errors {
+				if e.Field != "" {
+					apiErr.Reasons = append(apiErr.Reasons, e.Field+": "+e.Reason)
+				} else {
+					apiErr.Reasons = append(apiErr.Reasons, e.Reason)
+				}
+			}
+		}
+		return apiErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return errors.Wrapf(json.Unmarshal(data, out), "decoding response of %s %s", method, path)
+}
+
+// list walks every page of a paginated collection and calls add with the raw
+// items of each page.
+func (c *linodeClient) list(ctx context.Context, path string, add func(data json.RawMessage) error) error {
+	for page := 1; ; page++ {
+		var resp struct {
+			Data  json.RawMessage `json:"data"`
+			Page  int             `json:"page"`
+			Pages int             `json:"pages"`
+		}
+		if err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s?page=%d&page_size=500", path, page), nil, &resp); err != nil {
+			return err
+		}
+		if err := add(resp.Data); err != nil {
+			return errors.Wrapf(err, "decoding %s", path)
+		}
+		if page >= resp.Pages {
+			return nil
+		}
+	}
+}
+
+// linodeTimeLayout is the layout of timestamps returned by the Linode API, in UTC.
+const linodeTimeLayout = "2006-01-02T15:04:05"
+
+type lkeCluster struct {
+	ID         int      `json:"id"`
+	Label      string   `json:"label"`
+	Region     string   `json:"region"`
+	K8sVersion string   `json:"k8s_version"`
+	Status     string   `json:"status"`
+	Tags       []string `json:"tags"`
+	Created    string   `json:"created"`
+}
+
+type nodeBalancer struct {
+	ID                 int      `json:"id"`
+	Label              string   `json:"label"`
+	Region             string   `json:"region"`
+	Hostname           string   `json:"hostname"`
+	IPv4               string   `json:"ipv4"`
+	ClientConnThrottle int      `json:"client_conn_throttle"`
+	Tags               []string `json:"tags"`
+	Created            string   `json:"created"`
+}
+
+// NodeBalancerConfig is the configuration of one port of a NodeBalancer.
+type NodeBalancerConfig struct {
+	ID            int    `json:"id"`
+	Port          int    `json:"port"`
+	Protocol      string `json:"protocol"`
+	Algorithm     string `json:"algorithm"`
+	Stickiness    string `json:"stickiness"`
+	Check         string `json:"check"`
+	CheckInterval int    `json:"check_interval"`
+	CheckTimeout  int    `json:"check_timeout"`
+	CheckAttempts int    `json:"check_attempts"`
+	CheckPath     string `json:"check_path"`
+	CheckBody     string `json:"check_body"`
+	CheckPassive  bool   `json:"check_passive"`
+	ProxyProtocol string `json:"proxy_protocol"`
+	SSLCommonName string `json:"ssl_commonname"`
+	NodesStatus   struct {
+		Up   int `json:"up"`
+		Down int `json:"down"`
+	} `json:"nodes_status"`
+}
+
+type nodeBalancerCreateOptions struct {
+	Label  string   `json:"label,omitempty"`
+	Region string   `json:"region"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+type volume struct {
+	ID       int      `json:"id"`
+	Label    string   `json:"label"`
+	Region   string   `json:"region"`
+	Size     int      `json:"size"`
+	Status   string   `json:"status"`
+	LinodeID *int     `json:"linode_id"`
+	Tags     []string `json:"tags"`
+	Created  string   `json:"created"`
+}
+
+type instance struct {
+	ID      int      `json:"id"`
+	Label   string   `json:"label"`
+	Region  string   `json:"region"`
+	Tags    []string `json:"tags"`
+	Created string   `json:"created"`
+}
+
+type lkeNodePool struct {
+	ID    int       `json:"id"`
+	Type  string    `json:"type"`
+	Count int       `json:"count"`
+	Nodes []lkeNode `json:"nodes"`
+}
+
+type lkeNode struct {
+	ID         string `json:"id"`
+	InstanceID int    `json:"instance_id"`
+	Status     string `json:"status"`
+}
+
+type lkeNodePoolAutoscaler struct {
+	Enabled bool `json:"enabled"`
+	Min     int  `json:"min"`
+	Max     int  `json:"max"`
+}
+
+type lkeNodePoolCreateOptions struct {
+	Type       string                 `json:"type"`
+	Count      int                    `json:"count"`
+	Autoscaler *lkeNodePoolAutoscaler `json:"autoscaler,omitempty"`
+}
+
+type lkeControlPlaneOptions struct {
+	HighAvailability bool `json:"high_availability"`
+}
+
+type lkeClusterCreateOptions struct {
+	Label        string                     `json:"label"`
+	Region       string                     `json:"region"`
+	K8sVersion   string                     `json:"k8s_version"`
+	NodePools    []lkeNodePoolCreateOptions `json:"node_pools"`
+	Tags         []string                   `json:"tags,omitempty"`
+	ControlPlane *lkeControlPlaneOptions    `json:"control_plane,omitempty"`
+}
+
+func (c *linodeClient) CreateLKECluster(ctx context.Context, opts lkeClusterCreateOptions) (*lkeCluster, error) {
+	cluster := &lkeCluster{}
+	if err := c.do(ctx, http.MethodPost, "/lke/clusters", opts, cluster); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (c *linodeClient) ListLKEClusters(ctx context.Context) ([]lkeCluster, error) {
+	var clusters []lkeCluster
+	err := c.list(ctx, "/lke/clusters", func(data json.RawMessage) error {
+		var page []lkeCluster
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		clusters = append(clusters, page...)
+		return nil
+	})
+	return clusters, err
+}
+
+func (c *linodeClient) ListLKENodePools(ctx context.Context, clusterID int) ([]lkeNodePool, error) {
+	var pools []lkeNodePool
+	err := c.list(ctx, fmt.Sprintf("/lke/clusters/%d/pools", clusterID), func(data json.RawMessage) error {
+		var page []lkeNodePool
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		pools = append(pools, page...)
+		return nil
+	})
+	return pools, err
+}
+
+func (c *linodeClient) GetLKEClusterKubeconfig(ctx context.Context, clusterID int) (string, error) {
+	var resp struct {
+		Kubeconfig string `json:"kubeconfig"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/lke/clusters/%d/kubeconfig", clusterID), nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Kubeconfig, nil
+}
+
+func (c *linodeClient) DeleteLKECluster(ctx context.Context, clusterID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/lke/clusters/%d", clusterID), nil, nil)
+}
+
+func (c *linodeClient) ListLKEVersions(ctx context.Context) ([]string, error) {
+	var page struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/lke/versions", nil, &page); err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(page.Data))
+	for _, v := range page.Data {
+		versions = append(versions, v.ID)
+	}
+	return versions, nil
+}
+
+func (c *linodeClient) ListNodeBalancers(ctx context.Context) ([]nodeBalancer, error) {
+	var nodeBalancers []nodeBalancer
+	err := c.list(ctx, "/nodebalancers", func(data json.RawMessage) error {
+		var page []nodeBalancer
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		nodeBalancers = append(nodeBalancers, page...)
+		return nil
+	})
+	return nodeBalancers, err
+}
+
+func (c *linodeClient) GetNodeBalancer(ctx context.Context, id int) (*nodeBalancer, error) {
+	nb := &nodeBalancer{}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/nodebalancers/%d", id), nil, nb); err != nil {
+		return nil, err
+	}
+	return nb, nil
+}
+
+func (c *linodeClient) CreateNodeBalancer(ctx context.Context, opts nodeBalancerCreateOptions) (*nodeBalancer, error) {
+	nb := &nodeBalancer{}
+	if err := c.do(ctx, http.MethodPost, "/nodebalancers", opts, nb); err != nil {
+		return nil, err
+	}
+	return nb, nil
+}
+
+func (c *linodeClient) ListNodeBalancerConfigs(ctx context.Context, id int) ([]NodeBalancerConfig, error) {
+	var configs []NodeBalancerConfig
+	err := c.list(ctx, fmt.Sprintf("/nodebalancers/%d/configs", id), func(data json.RawMessage) error {
+		var page []NodeBalancerConfig
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		configs = append(configs, page...)
+		return nil
+	})
+	return configs, err
+}
+
+func (c *linodeClient) DeleteNodeBalancer(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/nodebalancers/%d", id), nil, nil)
+}
+
+func (c *linodeClient) ListInstances(ctx context.Context) ([]instance, error) {
+	var instances []instance
+	err := c.list(ctx, "/linode/instances", func(data json.RawMessage) error {
+		var page []instance
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		instances = append(instances, page...)
+		return nil
+	})
+	return instances, err
+}
+
+func (c *linodeClient) DeleteInstance(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/linode/instances/%d", id), nil, nil)
+}
+
+func (c *linodeClient) ListVolumes(ctx context.Context) ([]volume, error) {
+	var volumes []volume
+	err := c.list(ctx, "/volumes", func(data json.RawMessage) error {
+		var page []volume
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		volumes = append(volumes, page...)
+		return nil
+	})
+	return volumes, err
+}
+
+func (c *linodeClient) GetVolume(ctx context.Context, id int) (*volume, error) {
+	v := &volume{}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/volumes/%d", id), nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *linodeClient) UpdateVolumeTags(ctx context.Context, id int, tags []string) error {
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("/volumes/%d", id), map[string][]string{"tags": tags}, nil)
+}
+
+func (c *linodeClient) DeleteVolume(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/volumes/%d", id), nil, nil)
+}
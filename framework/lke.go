@@ -0,0 +1,218 @@
+package framework
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	lkeNodeStatusReady = "ready"
+)
+
+// LKEProvisioner provisions clusters through the Linode Kubernetes Engine API.
+type LKEProvisioner struct {
+	KubeconfigDir string
+	PollInterval  time.Duration
+	Timeout       time.Duration
+
+	client     *linodeClient
+	cluster    *lkeCluster
+	kubeconfig string
+}
+
+var _ ClusterProvisioner = &LKEProvisioner{}
+
+func NewLKEProvisioner(apiToken string) *LKEProvisioner {
+	return &LKEProvisioner{
+		PollInterval: 10 * time.Second,
+		Timeout:      20 * time.Minute,
+		client:       newLinodeClient(LinodeURL, apiToken),
+	}
+}
+
+func (p *LKEProvisioner) Create(ctx context.Context, spec ClusterSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	k8sVersion := spec.KubernetesVersion
+	if k8sVersion == "" {
+		latest, err := p.latestVersion(ctx)
+		if err != nil {
+			return err
+		}
+		k8sVersion = latest
+	}
+
+	opts := lkeClusterCreateOptions{
+		Label:      spec.Name,
+		Region:     spec.Region,
+		K8sVersion: k8sVersion,
+		Tags:       append([]string(nil), spec.Tags...),
+	}
+	for _, tag := range ResourceTags() {
+		if !containsString(opts.Tags, tag) {
+			opts.Tags = append(opts.Tags, tag)
+		}
+	}
+	if spec.HighAvailability {
+		opts.ControlPlane = &lkeControlPlaneOptions{HighAvailability: true}
+	}
+	for _, pool := range spec.NodePools {
+		poolOpts := lkeNodePoolCreateOptions{Type: pool.Type, Count: pool.Count}
+		if pool.Autoscaler != nil {
+			poolOpts.Autoscaler = &lkeNodePoolAutoscaler{Enabled: true, Min: pool.Autoscaler.Min, Max: pool.Autoscaler.Max}
+		}
+		opts.NodePools = append(opts.NodePools, poolOpts)
+	}
+
+	glog.Infof("Creating LKE cluster %q (%s, %d nodes, k8s %s)", spec.Name, spec.Region, spec.NodeCount(), k8sVersion)
+	cluster, err := p.client.CreateLKECluster(ctx, opts)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create LKE cluster %s", spec.Name)
+	}
+	p.cluster = cluster
+
+	if err := p.waitForNodes(ctx); err != nil {
+		return err
+	}
+
+	data, err := p.waitForKubeconfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	dir := p.KubeconfigDir
+	if dir == "" {
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	kubeconfig := filepath.Join(dir, spec.Name+".conf")
+	if err := ioutil.WriteFile(kubeconfig, data, 0600); err != nil {
+		return err
+	}
+	p.kubeconfig = kubeconfig
+
+	return nil
+}
+
+func (p *LKEProvisioner) Kubeconfig(ctx context.Context) (string, error) {
+	if p.kubeconfig == "" {
+		return "", ErrClusterNotCreated
+	}
+	return p.kubeconfig, nil
+}
+
+func (p *LKEProvisioner) Delete(ctx context.Context) error {
+	if p.cluster == nil {
+		return ErrClusterNotCreated
+	}
+
+	glog.Infof("Deleting LKE cluster %q (%d)", p.cluster.Label, p.cluster.ID)
+	err := p.client.DeleteLKECluster(ctx, p.cluster.ID)
+	if err != nil && !isAPIStatus(err, 404) {
+		return errors.Wrapf(err, "failed to delete LKE cluster %s", p.cluster.Label)
+	}
+
+	if p.kubeconfig != "" {
+		if err := os.Remove(p.kubeconfig); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	p.cluster = nil
+	p.kubeconfig = ""
+
+	return nil
+}
+
+func (p *LKEProvisioner) Describe() string {
+	if p.cluster != nil {
+		return fmt.Sprintf("LKE cluster %s (%d) in %s running k8s %s", p.cluster.Label, p.cluster.ID, p.cluster.Region, p.cluster.K8sVersion)
+	}
+	return "LKE"
+}
+
+func (p *LKEProvisioner) latestVersion(ctx context.Context) (string, error) {
+	versions, err := p.client.ListLKEVersions(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list LKE versions")
+	}
+
+	var (
+		latest  string
+		latestV *version.Version
+	)
+	for _, v := range versions {
+		parsed, err := version.ParseGeneric(v)
+		if err != nil {
+			continue
+		}
+		if latestV == nil || latestV.LessThan(parsed) {
+			latest, latestV = v, parsed
+		}
+	}
+	if latest == "" {
+		return "", errors.New("no LKE versions available")
+	}
+	return latest, nil
+}
+
+func (p *LKEProvisioner) waitForNodes(ctx context.Context) error {
+	err := poll("lke-nodes-ready", p.PollInterval, p.Timeout, func() (bool, error) {
+		pools, err := p.client.ListLKENodePools(ctx, p.cluster.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			glog.Warningf("Listing node pools of LKE cluster %d: %v", p.cluster.ID, err)
+			return false, nil
+		}
+		if len(pools) == 0 {
+			return false, nil
+		}
+		for _, pool := range pools {
+			if len(pool.Nodes) < pool.Count {
+				return false, nil
+			}
+			for _, node := range pool.Nodes {
+				if node.Status != lkeNodeStatusReady {
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+	return errors.Wrapf(err, "waiting for nodes of LKE cluster %s", p.cluster.Label)
+}
+
+func (p *LKEProvisioner) waitForKubeconfig(ctx context.Context) ([]byte, error) {
+	var encoded string
+	err := poll("lke-kubeconfig", p.PollInterval, p.Timeout, func() (bool, error) {
+		var err error
+		encoded, err = p.client.GetLKEClusterKubeconfig(ctx, p.cluster.ID)
+		if err != nil {
+			// The kubeconfig is served with 503 until the control plane is up.
+			if ctx.Err() != nil || isAPIStatus(err, 401) || isAPIStatus(err, 403) {
+				return false, err
+			}
+			return false, nil
+		}
+		return encoded != "", nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for kubeconfig of LKE cluster %s", p.cluster.Label)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	return data, errors.Wrap(err, "decoding kubeconfig")
+}
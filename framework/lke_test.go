@@ -0,0 +1,113 @@
+package framework
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("LKEProvisioner", func() {
+	var (
+		api  *fakeLinodeAPI
+		p    *LKEProvisioner
+		spec ClusterSpec
+		ctx  = context.Background()
+	)
+
+	BeforeEach(func() {
+		api = newFakeLinodeAPI()
+		DeferCleanup(api.Close)
+
+		p = NewLKEProvisioner("fake-token")
+		p.client = api.client()
+		p.KubeconfigDir = GinkgoT().TempDir()
+		p.PollInterval = time.Millisecond
+		p.Timeout = time.Second
+
+		spec = DefaultClusterSpec()
+		spec.Name = "e2e-cluster"
+	})
+
+	It("creates a cluster, waits for it and writes the kubeconfig", func() {
+		api.pollsUntilReady = 3
+		api.kubeconfigUnavailable = 2
+
+		Expect(p.Create(ctx, spec)).To(Succeed())
+
+		Expect(api.clusters).To(HaveLen(1))
+		created := api.clusters[1].cluster
+		Expect(created.Label).To(Equal("e2e-cluster"))
+		Expect(created.Region).To(Equal("eu-west"))
+		Expect(created.K8sVersion).To(Equal("1.26"))
+		Expect(api.clusters[1].pools).To(ConsistOf(HaveField("Count", 2)))
+
+		kubeconfig, err := p.Kubeconfig(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		data, err := ioutil.ReadFile(kubeconfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("kubeconfig-for-e2e-cluster"))
+	})
+
+	It("creates the cluster with the requested shape", func() {
+		spec.Region = "us-east"
+		spec.KubernetesVersion = "1.25"
+		spec.HighAvailability = true
+		spec.Tags = []string{"e2e"}
+		spec.NodePools = []NodePoolSpec{
+			{Type: "g6-standard-4", Count: 3, Autoscaler: &AutoscalerSpec{Min: 3, Max: 6}},
+			{Type: "g6-dedicated-2", Count: 1},
+		}
+
+		Expect(p.Create(ctx, spec)).To(Succeed())
+
+		Expect(api.created).To(HaveLen(1))
+		opts := api.created[0]
+		Expect(opts.Region).To(Equal("us-east"))
+		Expect(opts.K8sVersion).To(Equal("1.25"))
+		Expect(opts.Tags).To(ConsistOf("e2e", E2ETag))
+		Expect(opts.ControlPlane).To(Equal(&lkeControlPlaneOptions{HighAvailability: true}))
+		Expect(opts.NodePools).To(Equal([]lkeNodePoolCreateOptions{
+			{Type: "g6-standard-4", Count: 3, Autoscaler: &lkeNodePoolAutoscaler{Enabled: true, Min: 3, Max: 6}},
+			{Type: "g6-dedicated-2", Count: 1},
+		}))
+		Expect(p.Describe()).To(ContainSubstring("us-east"))
+	})
+
+	It("deletes the cluster and its kubeconfig", func() {
+		Expect(p.Create(ctx, spec)).To(Succeed())
+		kubeconfig, err := p.Kubeconfig(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(p.Delete(ctx)).To(Succeed())
+
+		Expect(api.clusters).To(BeEmpty())
+		_, err = os.Stat(kubeconfig)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		Expect(p.Delete(ctx)).To(MatchError(ErrClusterNotCreated))
+	})
+
+	It("returns typed API errors", func() {
+		api.createErrors = []string{"Region is not available"}
+
+		err := p.Create(ctx, spec)
+
+		var apiErr *APIError
+		Expect(errors.As(err, &apiErr)).To(BeTrue())
+		Expect(apiErr.StatusCode).To(Equal(400))
+		Expect(apiErr.Reasons).To(ConsistOf("Region is not available"))
+		_, err = p.Kubeconfig(ctx)
+		Expect(err).To(MatchError(ErrClusterNotCreated))
+	})
+
+	It("times out when nodes never become ready", func() {
+		api.pollsUntilReady = 1 << 30
+		p.Timeout = 20 * time.Millisecond
+
+		Expect(p.Create(ctx, spec)).To(MatchError(ContainSubstring("waiting for nodes")))
+	})
+})
@@ -0,0 +1,330 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+	"github.com/pkg/errors"
+)
+
+// loadEntry names the report entries summarizing the traffic of a
+// LoadGenerator.
+const loadEntry = "Load"
+
+// latencyBuckets are the upper bounds of the latency histogram of a LoadReport.
+var latencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LoadGenerator sends the requests of a Probe at a fixed rate in the background
+// and records every response, to measure how many requests a LoadBalancer drops
+// while nodes drain, the CCM restarts or backends roll:
+//
+//	load := framework.NewLoadGenerator(framework.NewProbe(url).WithTimeout(2*time.Second), 20)
+//	load.Start()
+//	start := time.Now()
+//	... disrupt ...
+//	report := load.Stop()
+//	Expect(report.Between(start, time.Now()).CheckAvailability(0.999)).To(Succeed())
+//
+// Requests are sent on schedule even while earlier ones are outstanding, so a
+// hanging backend does not lower the rate.
+type LoadGenerator struct {
+	probe    *Probe
+	interval time.Duration
+	name     string
+	// ticker paces the requests, tests drive it by hand.
+	ticker func(time.Duration) (<-chan time.Time, func())
+
+	mu      sync.Mutex
+	samples []LoadSample
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// LoadSample is a single request sent by a LoadGenerator.
+type LoadSample struct {
+	Time    time.Time
+	Latency time.Duration
+	// Err is set when the request failed or the response did not match the
+	// probe.
+	Err error
+}
+
+// NewLoadGenerator sends the requests of probe rate times per second.
+func NewLoadGenerator(probe *Probe, rate int) *LoadGenerator {
+	if rate < 1 {
+		rate = 1
+	}
+	return &LoadGenerator{
+		probe:    probe,
+		interval: time.Second / time.Duration(rate),
+		name:     probe.url,
+		ticker: func(interval time.Duration) (<-chan time.Time, func()) {
+			t := time.NewTicker(interval)
+			return t.C, t.Stop
+		},
+	}
+}
+
+// WithName names the report entry of the load, the probe URL by default.
+func (g *LoadGenerator) WithName(name string) *LoadGenerator {
+	g.name = name
+	return g
+}
+
+// Start starts sending requests until Stop is called.
+func (g *LoadGenerator) Start() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stop != nil {
+		return
+	}
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	go g.run(g.stop, g.done)
+}
+
+func (g *LoadGenerator) run(stop, done chan struct{}) {
+	defer close(done)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticks, stopTicker := g.ticker(g.interval)
+	defer stopTicker()
+	for {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.send()
+		}()
+
+		select {
+		case <-stop:
+			return
+		case <-ticks:
+		}
+	}
+}
+
+func (g *LoadGenerator) send() {
+	start := time.Now()
+	_, err := g.probe.Do()
+	sample := LoadSample{Time: start, Latency: time.Since(start), Err: err}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.samples = append(g.samples, sample)
+}
+
+// Stop stops sending requests, waits for the outstanding ones and returns the
+// report of all requests sent since Start. The summary is added to the report
+// of the current spec. Stopping again only returns the report, so Stop can
+// also be deferred.
+func (g *LoadGenerator) Stop() *LoadReport {
+	g.mu.Lock()
+	stop, done := g.stop, g.done
+	g.stop = nil
+	g.mu.Unlock()
+
+	if stop == nil {
+		return g.Report()
+	}
+	close(stop)
+	<-done
+
+	report := g.Report()
+	if ginkgo.CurrentSpecReport().LeafNodeType != types.NodeTypeInvalid {
+		ginkgo.AddReportEntry(loadEntry+" "+g.name, report.String(), ginkgo.ReportEntryVisibilityFailureOrVerbose)
+	}
+	return report
+}
+
+// Report returns the report of the requests finished so far, it can be called
+// while the generator runs.
+func (g *LoadGenerator) Report() *LoadReport {
+	g.mu.Lock()
+	samples := make([]LoadSample, len(g.samples))
+	copy(samples, g.samples)
+	g.mu.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+	return &LoadReport{Samples: samples}
+}
+
+// LoadReport is the outcome of the requests of a LoadGenerator, ordered by the
+// time they were sent.
+type LoadReport struct {
+	Samples []LoadSample
+}
+
+// LoadInterval is the traffic of one interval of a LoadReport timeline.
+type LoadInterval struct {
+	Start    time.Time
+	Requests int
+	Failures int
+}
+
+// LatencyBucket counts the requests that took at most UpperBound and longer
+// than the previous bucket. The last bucket has no upper bound.
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      int
+}
+
+// Between returns the report of the requests sent in [from, to).
+func (r *LoadReport) Between(from, to time.Time) *LoadReport {
+	var samples []LoadSample
+	for _, s := range r.Samples {
+		if !s.Time.Before(from) && s.Time.Before(to) {
+			samples = append(samples, s)
+		}
+	}
+	return &LoadReport{Samples: samples}
+}
+
+func (r *LoadReport) Requests() int {
+	return len(r.Samples)
+}
+
+func (r *LoadReport) Failures() int {
+	n := 0
+	for _, s := range r.Samples {
+		if s.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Availability is the share of successful requests, 0 without requests.
+func (r *LoadReport) Availability() float64 {
+	if len(r.Samples) == 0 {
+		return 0
+	}
+	return float64(len(r.Samples)-r.Failures()) / float64(len(r.Samples))
+}
+
+// CheckAvailability returns an error describing the failures unless at least
+// min of the requests succeeded, e.g. 0.999 for 99.9%.
+func (r *LoadReport) CheckAvailability(min float64) error {
+	if len(r.Samples) == 0 {
+		return errors.New("no requests were sent")
+	}
+	if r.Availability() >= min {
+		return nil
+	}
+	return errors.Errorf("availability %.3f%% is below %.3f%%\n%s", r.Availability()*100, min*100, r)
+}
+
+// Histogram counts the latencies of all requests, failed ones included.
+func (r *LoadReport) Histogram() []LatencyBucket {
+	buckets := make([]LatencyBucket, len(latencyBuckets)+1)
+	for i, bound := range latencyBuckets {
+		buckets[i].UpperBound = bound
+	}
+	for _, s := range r.Samples {
+		i := sort.Search(len(latencyBuckets), func(i int) bool {
+			return s.Latency <= latencyBuckets[i]
+		})
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// Percentile returns the latency that q (0 to 1) of the requests did not
+// exceed.
+func (r *LoadReport) Percentile(q float64) time.Duration {
+	if len(r.Samples) == 0 {
+		return 0
+	}
+	latencies := make([]time.Duration, len(r.Samples))
+	for i, s := range r.Samples {
+		latencies[i] = s.Latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	i := int(q*float64(len(latencies))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(latencies) {
+		i = len(latencies) - 1
+	}
+	return latencies[i]
+}
+
+// Timeline counts requests and failures per interval, starting at the first
+// request. Intervals without requests are included, they show the generator
+// itself stalled.
+func (r *LoadReport) Timeline(interval time.Duration) []LoadInterval {
+	if len(r.Samples) == 0 {
+		return nil
+	}
+	start := r.Samples[0].Time
+	n := int(r.Samples[len(r.Samples)-1].Time.Sub(start)/interval) + 1
+	timeline := make([]LoadInterval, n)
+	for i := range timeline {
+		timeline[i].Start = start.Add(time.Duration(i) * interval)
+	}
+	for _, s := range r.Samples {
+		i := int(s.Time.Sub(start) / interval)
+		timeline[i].Requests++
+		if s.Err != nil {
+			timeline[i].Failures++
+		}
+	}
+	return timeline
+}
+
+// Errors counts the failed requests by error message.
+func (r *LoadReport) Errors() map[string]int {
+	errs := map[string]int{}
+	for _, s := range r.Samples {
+		if s.Err != nil {
+			errs[s.Err.Error()]++
+		}
+	}
+	return errs
+}
+
+func (r *LoadReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d requests, %d failed, availability %.3f%%, latency p50 %s p99 %s max %s",
+		r.Requests(), r.Failures(), r.Availability()*100, r.Percentile(0.5), r.Percentile(0.99), r.Percentile(1))
+
+	errs := r.Errors()
+	messages := make([]string, 0, len(errs))
+	for msg := range errs {
+		messages = append(messages, msg)
+	}
+	sort.Strings(messages)
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "\n  %dx %s", errs[msg], msg)
+	}
+
+	for _, i := range r.Timeline(10 * time.Second) {
+		if i.Failures > 0 {
+			fmt.Fprintf(&b, "\n  %s: %d of %d failed", i.Start.Format(time.RFC3339), i.Failures, i.Requests)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package framework
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LoadGenerator", func() {
+	var (
+		server *httptest.Server
+		// failing makes the server answer 503 while set.
+		failing int32
+		// ticks paces the generators of a spec instead of the clock.
+		ticks chan time.Time
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&failing, 0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		DeferCleanup(server.Close)
+		ticks = make(chan time.Time)
+	})
+
+	newLoad := func(probe *Probe) *LoadGenerator {
+		load := NewLoadGenerator(probe, 100)
+		load.ticker = func(time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+		return load
+	}
+
+	// send sends n ticks and waits until the generator finished total
+	// requests, so that each phase of a spec is over before the next.
+	send := func(load *LoadGenerator, n, total int) {
+		for i := 0; i < n; i++ {
+			ticks <- time.Now()
+		}
+		Eventually(func() int { return load.Report().Requests() }, 10*time.Second).Should(Equal(total))
+	}
+
+	It("sends a request right away and one per tick", func() {
+		load := newLoad(NewProbe(server.URL))
+		load.Start()
+		send(load, 29, 30)
+		report := load.Stop()
+
+		Expect(report.Requests()).To(Equal(30))
+		Expect(report.Failures()).To(BeZero())
+		Expect(report.CheckAvailability(1)).To(Succeed())
+
+		By("not sending anything after Stop")
+		Expect(load.Stop().Requests()).To(Equal(30))
+	})
+
+	It("adds its summary to the spec report once", func() {
+		load := newLoad(NewProbe(server.URL)).WithName("backend")
+		load.Start()
+		send(load, 1, 2)
+		load.Stop()
+		load.Stop()
+
+		var entries []string
+		for _, entry := range CurrentSpecReport().ReportEntries {
+			entries = append(entries, entry.Name)
+		}
+		Expect(entries).To(Equal([]string{"Load backend"}))
+	})
+
+	It("measures availability during an injected outage", func() {
+		load := newLoad(NewProbe(server.URL))
+		load.Start()
+		send(load, 9, 10)
+
+		outageStart := time.Now()
+		atomic.StoreInt32(&failing, 1)
+		send(load, 10, 20)
+		atomic.StoreInt32(&failing, 0)
+		outageEnd := time.Now()
+
+		send(load, 10, 30)
+		report := load.Stop()
+
+		Expect(report.Failures()).To(Equal(10))
+		Expect(report.Availability()).To(BeNumerically("~", 2.0/3, 0.001))
+		err := report.CheckAvailability(0.999)
+		Expect(err).To(MatchError(ContainSubstring("below 99.900%")))
+		Expect(err).To(MatchError(ContainSubstring("answered 503")))
+		Expect(report.Errors()).To(HaveLen(1))
+
+		outage := report.Between(outageStart, outageEnd)
+		Expect(outage.Requests()).To(Equal(10))
+		Expect(outage.Availability()).To(BeZero())
+		Expect(report.Between(outageEnd, time.Now()).CheckAvailability(1)).To(Succeed())
+	})
+
+	It("keeps sending while requests hang", func() {
+		var arrived int32
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&arrived, 1)
+			<-release
+		}))
+		defer slow.Close()
+
+		load := newLoad(NewProbe(slow.URL).WithTimeout(time.Minute))
+		load.Start()
+		for i := 0; i < 10; i++ {
+			ticks <- time.Now()
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&arrived) }, 10*time.Second).Should(BeEquivalentTo(11))
+		Expect(load.Report().Requests()).To(BeZero())
+
+		close(release)
+		report := load.Stop()
+		Expect(report.Requests()).To(Equal(11))
+		Expect(report.CheckAvailability(1)).To(Succeed())
+	})
+})
+
+var _ = Describe("LoadReport", func() {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(offset, latency time.Duration, failed bool) LoadSample {
+		s := LoadSample{Time: start.Add(offset), Latency: latency}
+		if failed {
+			s.Err = errors.New("connection refused")
+		}
+		return s
+	}
+	report := &LoadReport{Samples: []LoadSample{
+		sample(0, 3*time.Millisecond, false),
+		sample(time.Second, 40*time.Millisecond, false),
+		sample(2*time.Second, 40*time.Millisecond, true),
+		sample(5*time.Second, 2*time.Second, true),
+		sample(6*time.Second, 20*time.Second, false),
+	}}
+
+	It("buckets latencies", func() {
+		counts := map[time.Duration]int{}
+		for _, b := range report.Histogram() {
+			counts[b.UpperBound] += b.Count
+		}
+		Expect(counts).To(Equal(map[time.Duration]int{
+			5 * time.Millisecond:    1,
+			10 * time.Millisecond:   0,
+			25 * time.Millisecond:   0,
+			50 * time.Millisecond:   2,
+			100 * time.Millisecond:  0,
+			250 * time.Millisecond:  0,
+			500 * time.Millisecond:  0,
+			time.Second:             0,
+			2500 * time.Millisecond: 1,
+			5 * time.Second:         0,
+			10 * time.Second:        0,
+			0:                       1,
+		}))
+	})
+
+	It("computes percentiles", func() {
+		Expect(report.Percentile(0)).To(Equal(3 * time.Millisecond))
+		Expect(report.Percentile(0.5)).To(Equal(40 * time.Millisecond))
+		Expect(report.Percentile(1)).To(Equal(20 * time.Second))
+		Expect((&LoadReport{}).Percentile(0.5)).To(BeZero())
+	})
+
+	It("counts failures over time", func() {
+		Expect(report.Timeline(2 * time.Second)).To(Equal([]LoadInterval{
+			{Start: start, Requests: 2},
+			{Start: start.Add(2 * time.Second), Requests: 1, Failures: 1},
+			{Start: start.Add(4 * time.Second), Requests: 1, Failures: 1},
+			{Start: start.Add(6 * time.Second), Requests: 1},
+		}))
+		Expect(report.Errors()).To(Equal(map[string]int{"connection refused": 2}))
+	})
+
+	It("checks availability in a window", func() {
+		Expect(report.Availability()).To(BeNumerically("~", 0.6, 0.001))
+		Expect(report.Between(start, start.Add(2*time.Second)).CheckAvailability(1)).To(Succeed())
+		Expect(report.Between(start.Add(time.Second), start.Add(3*time.Second)).Availability()).To(Equal(0.5))
+		Expect(report.Between(start.Add(time.Minute), start.Add(2*time.Minute)).CheckAvailability(0.5)).
+			To(MatchError("no requests were sent"))
+	})
+})
@@ -0,0 +1,263 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+// fieldManager owns the fields of the objects the suite applies.
+const fieldManager = "linode-k8s-e2e-tests"
+
+// noMatchRetries is how many RetryIntervals a kind the cluster does not serve
+// is looked up again, unless its CRD was applied earlier in the manifest.
+const noMatchRetries = 3
+
+// ManifestVars are the variables of a manifest template, see ApplyManifest.
+type ManifestVars map[string]interface{}
+
+// ApplyManifest applies the objects of a YAML manifest with server-side apply
+// and returns them as the API server stored them. Objects of namespaced kinds
+// without a namespace go to the invocation namespace. Kinds of CRDs applied
+// earlier in the manifest are waited for up to Timeout, other kinds the
+// cluster does not serve fail after a few RetryIntervals. Objects the apply
+// created are deleted when the spec ends, the last applied first; objects
+// that existed before are left alone.
+//
+// The manifest is a text/template of any number of YAML documents. It can use
+// {{.Namespace}}, {{.Image}}, {{.DockerRegistry}}, {{.StorageClass}} and
+// {{.RunID}}, as well as vars, which take precedence:
+//
+//	objs, err := f.Cluster.ApplyManifest("manifest/web.yaml", framework.ManifestVars{"Replicas": 3})
+func (i *k8sInvocation) ApplyManifest(manifestPath string, vars ManifestVars) ([]*unstructured.Unstructured, error) {
+	data, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return i.ApplyManifestData(manifestPath, data, vars)
+}
+
+// ApplyManifestData applies a manifest like ApplyManifest, name only labels
+// errors.
+func (i *k8sInvocation) ApplyManifestData(name string, data []byte, vars ManifestVars) ([]*unstructured.Unstructured, error) {
+	return i.applyManifest(name, data, i.Namespace(), vars, func(obj *unstructured.Unstructured, resource dynamic.ResourceInterface, created bool) {
+		if !created {
+			return
+		}
+		name := obj.GetName()
+		i.tracker.track(obj.GetKind(), obj.GetNamespace(), name, func() error {
+			return resource.Delete(context.TODO(), name, *deleteInForeground())
+		})
+	})
+}
+
+// ApplyManifest applies a manifest like k8sInvocation.ApplyManifest, into
+// the framework namespace and without deleting the objects afterwards, e.g.
+// for what the whole suite needs.
+func (f *Framework) ApplyManifest(manifestPath string, vars ManifestVars) ([]*unstructured.Unstructured, error) {
+	data, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return f.applyManifest(manifestPath, data, f.namespace, vars, nil)
+}
+
+// applyManifest applies the objects of a manifest into namespace. applied, if
+// set, is called with every object and whether the apply created it.
+func (f *Framework) applyManifest(name string, data []byte, namespace string, vars ManifestVars, applied func(obj *unstructured.Unstructured, resource dynamic.ResourceInterface, created bool)) ([]*unstructured.Unstructured, error) {
+	rendered, err := renderManifest(name, data, manifestData(namespace, vars))
+	if err != nil {
+		return nil, err
+	}
+	objs, err := decodeManifest(rendered)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading manifest %s", name)
+	}
+
+	var out []*unstructured.Unstructured
+	// Groups of the CRDs applied so far, their kinds are waited for.
+	crdGroups := map[string]bool{}
+	for _, obj := range objs {
+		wait := noMatchRetries * RetryInterval
+		if crdGroups[obj.GroupVersionKind().Group] {
+			wait = Timeout
+		}
+		resource, err := f.resourceFor(obj, namespace, wait)
+		if err != nil {
+			return out, errors.Wrapf(err, "applying %s %s from %s", obj.GetKind(), obj.GetName(), name)
+		}
+		body, err := json.Marshal(obj.Object)
+		if err != nil {
+			return out, err
+		}
+		var created bool
+		if applied != nil {
+			_, err := resource.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+			if err != nil && !kerr.IsNotFound(err) {
+				return out, errors.Wrapf(err, "applying %s %s from %s", obj.GetKind(), obj.GetName(), name)
+			}
+			created = kerr.IsNotFound(err)
+		}
+		force := true
+		result, err := resource.Patch(context.TODO(), obj.GetName(), types.ApplyPatchType, body, metav1.PatchOptions{
+			FieldManager: fieldManager,
+			Force:        &force,
+		})
+		if err != nil {
+			return out, errors.Wrapf(err, "applying %s %s from %s", obj.GetKind(), obj.GetName(), name)
+		}
+		if applied != nil {
+			applied(result, resource, created)
+		}
+		if gk := obj.GroupVersionKind().GroupKind(); gk.Group == "apiextensions.k8s.io" && gk.Kind == "CustomResourceDefinition" {
+			group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+			crdGroups[group] = true
+		}
+		out = append(out, result)
+	}
+	return out, nil
+}
+
+// manifestData returns the template data of a manifest applied to namespace.
+func manifestData(namespace string, vars ManifestVars) ManifestVars {
+	data := ManifestVars{
+		"Namespace":      namespace,
+		"Image":          Image,
+		"DockerRegistry": DockerRegistry,
+		"StorageClass":   StorageClass,
+		"RunID":          RunID,
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	return data
+}
+
+// renderManifest executes the manifest as a template. Unknown variables are
+// an error rather than an empty string.
+func renderManifest(name string, data []byte, vars ManifestVars) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing manifest %s", name)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return nil, errors.Wrapf(err, "rendering manifest %s", name)
+	}
+	return out.Bytes(), nil
+}
+
+// decodeManifest splits multi-document YAML or JSON into objects. Empty
+// documents are skipped and Lists are flattened.
+func decodeManifest(data []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err := decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, errors.Errorf("object %q has no kind or apiVersion", obj.GetName())
+		}
+		if !obj.IsList() {
+			objs = append(objs, obj)
+			continue
+		}
+		err = obj.EachListItem(func(item runtime.Object) error {
+			objs = append(objs, item.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// resourceFor returns the client of the resource of obj, setting namespace
+// on objects of namespaced kinds that have none. An unknown kind is looked up
+// again for up to wait, see restMapping.
+func (f *Framework) resourceFor(obj *unstructured.Unstructured, namespace string, wait time.Duration) (dynamic.ResourceInterface, error) {
+	mapping, err := f.restMapping(obj.GroupVersionKind(), wait)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		obj.SetNamespace("")
+		return f.dynamicClient.Resource(mapping.Resource), nil
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
+	}
+	return f.dynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// restMapping maps a kind to its resource. Kinds of CRDs applied moments ago
+// are unknown until the CRD is established, and kinds served since the
+// discovery cache was filled are unknown to it, so the cache is reset and the
+// mapping retried for up to wait.
+func (f *Framework) restMapping(gvk schema.GroupVersionKind, wait time.Duration) (*meta.RESTMapping, error) {
+	var mapping *meta.RESTMapping
+	err := poll("rest-mapping", RetryInterval, wait, func() (bool, error) {
+		var err error
+		mapping, err = f.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			if r, ok := f.restMapper.(interface{ Reset() }); ok {
+				r.Reset()
+			}
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return mapping, errors.Wrapf(err, "mapping kind %s", gvk)
+}
+
+// WaitForObject waits up to Timeout until condition holds for an applied
+// object, see WaitForPod.
+func (i *k8sInvocation) WaitForObject(obj *unstructured.Unstructured, condition func(*unstructured.Unstructured) (bool, error)) (*unstructured.Unstructured, error) {
+	mapping, err := i.restMapping(obj.GroupVersionKind(), noMatchRetries*RetryInterval)
+	if err != nil {
+		return nil, err
+	}
+	u, err := waitForUnstructured(context.TODO(), i.dynamicClient, mapping.Resource, "object", i.Timeout, obj.GetNamespace(), obj.GetName(), condition)
+	return u, errors.Wrapf(err, "waiting for %s %s", obj.GetKind(), objectKey(obj))
+}
+
+// DeleteObject deletes an applied object and, in the foreground, its
+// dependents.
+func (i *k8sInvocation) DeleteObject(obj *unstructured.Unstructured) error {
+	resource, err := i.resourceFor(obj, obj.GetNamespace(), noMatchRetries*RetryInterval)
+	if err != nil {
+		return err
+	}
+	return resource.Delete(context.TODO(), obj.GetName(), *deleteInForeground())
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
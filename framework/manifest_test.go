@@ -0,0 +1,260 @@
+package framework
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	configMapGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	namespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	widgetGVR    = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+)
+
+// serveApply emulates server-side apply, which the fake dynamic client does
+// not support, by creating or replacing the applied object.
+func serveApply(client *dynamicfake.FakeDynamicClient) {
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		tracker := client.Tracker()
+		err := tracker.Create(patch.GetResource(), obj, patch.GetNamespace())
+		if kerr.IsAlreadyExists(err) {
+			err = tracker.Update(patch.GetResource(), obj, patch.GetNamespace())
+		}
+		return true, obj, err
+	})
+}
+
+var _ = Describe("Manifests", func() {
+	var (
+		inv       *Invocation
+		client    *dynamicfake.FakeDynamicClient
+		discovery *fakediscovery.FakeDiscovery
+		ctx       = context.TODO()
+	)
+
+	const manifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Namespace}}-extra
+---
+# comments and empty documents are skipped
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  image: {{.Image}}
+  replicas: "{{.Replicas}}"
+`
+
+	BeforeEach(func() {
+		var err error
+		inv, err = newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		client = inv.dynamicClient.(*dynamicfake.FakeDynamicClient)
+		serveApply(client)
+
+		discovery = inv.kubeClient.Discovery().(*fakediscovery.FakeDiscovery)
+		discovery.Resources = []*metav1.APIResourceList{{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+				{Name: "namespaces", Kind: "Namespace"},
+			},
+		}}
+	})
+
+	It("applies every document with its variables and deletes the objects with the spec", func() {
+		objs, err := inv.Cluster.ApplyManifestData("web.yaml", []byte(manifest), ManifestVars{"Replicas": 3})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		Expect(objs[0].GetName()).To(Equal(inv.Namespace() + "-extra"))
+		Expect(objs[0].GetNamespace()).To(BeEmpty())
+		Expect(objs[1].GetNamespace()).To(Equal(inv.Namespace()))
+		Expect(objs[1].Object).To(HaveKeyWithValue("data", map[string]interface{}{"image": Image, "replicas": "3"}))
+
+		for _, action := range client.Actions() {
+			if patch, ok := action.(k8stesting.PatchAction); ok {
+				Expect(patch.GetPatchType()).To(Equal(types.ApplyPatchType))
+			}
+		}
+		Expect(inv.Resources()).To(HaveLen(2))
+
+		Expect(inv.Cleanup()).To(Succeed())
+		configMaps, err := client.Resource(configMapGVR).Namespace(inv.Namespace()).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMaps.Items).To(BeEmpty())
+		namespaces, err := client.Resource(namespaceGVR).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespaces.Items).To(BeEmpty())
+	})
+
+	It("reapplies objects that already exist", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "web.yaml")
+		Expect(ioutil.WriteFile(path, []byte(manifest), 0644)).To(Succeed())
+
+		_, err := inv.Cluster.ApplyManifest(path, ManifestVars{"Replicas": 1})
+		Expect(err).NotTo(HaveOccurred())
+		objs, err := inv.Cluster.ApplyManifest(path, ManifestVars{"Replicas": 2, "Image": "nginx"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs[1].Object).To(HaveKeyWithValue("data", map[string]interface{}{"image": "nginx", "replicas": "2"}))
+
+		configMap, err := client.Resource(configMapGVR).Namespace(inv.Namespace()).Get(ctx, "settings", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMap.Object).To(HaveKeyWithValue("data", HaveKeyWithValue("replicas", "2")))
+		Expect(inv.Resources()).To(HaveLen(2))
+	})
+
+	It("leaves objects that existed before the apply", func() {
+		existing := &unstructured.Unstructured{}
+		existing.SetAPIVersion("v1")
+		existing.SetKind("ConfigMap")
+		existing.SetName("settings")
+		_, err := client.Resource(configMapGVR).Namespace(inv.Namespace()).Create(ctx, existing, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = inv.Cluster.ApplyManifestData("web.yaml", []byte(manifest), ManifestVars{"Replicas": 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Resources()).To(ConsistOf(HaveField("Kind", "Namespace")))
+
+		Expect(inv.Cleanup()).To(Succeed())
+		configMap, err := client.Resource(configMapGVR).Namespace(inv.Namespace()).Get(ctx, "settings", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMap.Object).To(HaveKeyWithValue("data", HaveKeyWithValue("replicas", "1")))
+	})
+
+	It("flattens Lists and keeps explicit namespaces", func() {
+		objs, err := inv.Cluster.ApplyManifestData("list.yaml", []byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: a
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: b
+    namespace: other
+`), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		Expect(objectKey(objs[0])).To(Equal(inv.Namespace() + "/a"))
+		Expect(objectKey(objs[1])).To(Equal("other/b"))
+	})
+
+	It("rejects undefined variables and objects without a kind", func() {
+		_, err := inv.Cluster.ApplyManifestData("web.yaml", []byte(manifest), nil)
+		Expect(err).To(MatchError(ContainSubstring("rendering manifest web.yaml")))
+		Expect(err).To(MatchError(ContainSubstring("Replicas")))
+
+		_, err = inv.Cluster.ApplyManifestData("bad.yaml", []byte("metadata:\n  name: nameless\n"), nil)
+		Expect(err).To(MatchError(ContainSubstring(`object "nameless" has no kind or apiVersion`)))
+		Expect(client.Actions()).To(BeEmpty())
+	})
+
+	It("picks up kinds served after the discovery cache was filled", func() {
+		_, err := inv.Cluster.ApplyManifestData("web.yaml", []byte(manifest), ManifestVars{"Replicas": 1})
+		Expect(err).NotTo(HaveOccurred())
+
+		discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+		})
+		objs, err := inv.Cluster.ApplyManifestData("widget.yaml", []byte(`apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: gear
+`), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(300 * time.Millisecond)
+			widget := objs[0].DeepCopy()
+			Expect(unstructured.SetNestedField(widget.Object, "Ready", "status", "phase")).To(Succeed())
+			_, err := client.Resource(widgetGVR).Namespace(inv.Namespace()).Update(ctx, widget, metav1.UpdateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		widget, err := inv.Cluster.WaitForObject(objs[0], func(u *unstructured.Unstructured) (bool, error) {
+			phase, _, err := unstructured.NestedString(u.Object, "status", "phase")
+			return phase == "Ready", err
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(widget.GetName()).To(Equal("gear"))
+
+		Expect(inv.Cluster.DeleteObject(widget)).To(Succeed())
+		_, err = client.Resource(widgetGVR).Namespace(inv.Namespace()).Get(ctx, "gear", metav1.GetOptions{})
+		Expect(kerr.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("fails on kinds the cluster does not serve without waiting for Timeout", func() {
+		start := time.Now()
+		_, err := inv.Cluster.ApplyManifestData("gadget.yaml", []byte(`apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: sprocket
+`), nil)
+		Expect(err).To(MatchError(ContainSubstring("applying Gadget sprocket from gadget.yaml")))
+		Expect(err).To(MatchError(ContainSubstring("mapping kind example.com/v1, Kind=Gadget")))
+		Expect(time.Since(start)).To(BeNumerically("<", Timeout/2))
+	})
+
+	It("waits for the kinds of CRDs applied earlier in the manifest", func() {
+		discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{
+			GroupVersion: "apiextensions.k8s.io/v1",
+			APIResources: []metav1.APIResource{{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition"}},
+		})
+		// The CRD is established after a few discovery refreshes, more than
+		// an unknown kind is retried for.
+		refreshes := 0
+		discovery.PrependReactor("get", "group", func(k8stesting.Action) (bool, runtime.Object, error) {
+			if refreshes++; refreshes == 3*noMatchRetries {
+				discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{
+					GroupVersion: "example.com/v1",
+					APIResources: []metav1.APIResource{{Name: "gadgets", Kind: "Gadget", Namespaced: true}},
+				})
+			}
+			return false, nil, nil
+		})
+
+		objs, err := inv.Cluster.ApplyManifestData("gadget.yaml", []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gadgets.example.com
+spec:
+  group: example.com
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: sprocket
+`), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		Expect(objectKey(objs[1])).To(Equal(inv.Namespace() + "/sprocket"))
+	})
+})
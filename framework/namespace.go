@@ -2,12 +2,49 @@ package framework
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
 	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
 )
 
+const (
+	runIDLabel = "e2e.linode.com/run-id"
+	specLabel  = "e2e.linode.com/spec"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// NamespaceStuckError is returned when a namespace is still terminating after
+// the timeout. It lists what is blocking the termination.
+type NamespaceStuckError struct {
+	Name       string
+	Finalizers []string
+	// Conditions holds the messages of the namespace deletion conditions,
+	// e.g. the kinds of the remaining resources and their finalizers.
+	Conditions []string
+}
+
+func (e *NamespaceStuckError) Error() string {
+	msg := fmt.Sprintf("namespace %s is stuck terminating", e.Name)
+	if len(e.Finalizers) > 0 {
+		msg += fmt.Sprintf(", finalizers %v", e.Finalizers)
+	}
+	if len(e.Conditions) > 0 {
+		msg += ": " + strings.Join(e.Conditions, "; ")
+	}
+	return msg
+}
+
 func (f *Framework) Namespace() string {
 	return f.namespace
 }
@@ -16,16 +53,96 @@ func (f *Framework) RestConfig() *rest.Config {
 	return f.restConfig
 }
 
+// CreateNamespace creates the framework namespace and waits until it is Active.
 func (f *Framework) CreateNamespace() error {
+	return f.createNamespace(f.namespace, map[string]string{
+		runIDLabel: labelValue(RunID),
+	})
+}
+
+// DeleteNamespace deletes the framework namespace and waits until it is gone.
+func (f *Framework) DeleteNamespace() error {
+	return f.deleteNamespace(f.namespace)
+}
+
+// DeleteStaleNamespaces deletes namespaces left behind by earlier runs: those
+// labelled with a run ID other than the current one and older than ttl.
+func (f *Framework) DeleteStaleNamespaces(ttl time.Duration) error {
+	list, err := f.kubeClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: runIDLabel,
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, ns := range list.Items {
+		if ns.Labels[runIDLabel] == labelValue(RunID) || time.Since(ns.CreationTimestamp.Time) < ttl {
+			continue
+		}
+		glog.Infof("Deleting namespace %s of run %s", ns.Name, ns.Labels[runIDLabel])
+		if err := f.deleteNamespace(ns.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (f *Framework) createNamespace(name string, labels map[string]string) error {
 	obj := &core.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: f.namespace,
+			Name:   name,
+			Labels: labels,
 		},
 	}
 	_, err := f.kubeClient.CoreV1().Namespaces().Create(context.TODO(), obj, metav1.CreateOptions{})
-	return err
+	if err != nil {
+		return err
+	}
+
+	_, err = waitForNamespace(context.TODO(), f.kubeClient, "namespace-active", Timeout, name, func(ns *core.Namespace) (bool, error) {
+		return ns != nil && ns.Status.Phase == core.NamespaceActive, nil
+	})
+	return errors.Wrapf(err, "waiting for namespace %s to become active", name)
 }
 
-func (f *Framework) DeleteNamespace() error {
-	return f.kubeClient.CoreV1().Namespaces().Delete(context.TODO(), f.namespace, *deleteInForeground())
+func (f *Framework) deleteNamespace(name string) error {
+	err := f.kubeClient.CoreV1().Namespaces().Delete(context.TODO(), name, *deleteInForeground())
+	if kerr.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ns, err := waitForNamespace(context.TODO(), f.kubeClient, "namespace-deleted", Timeout, name, func(ns *core.Namespace) (bool, error) {
+		return ns == nil, nil
+	})
+	if err == wait.ErrWaitTimeout && ns != nil {
+		return namespaceStuckError(ns)
+	}
+	return errors.Wrapf(err, "waiting for namespace %s to be deleted", name)
+}
+
+func namespaceStuckError(ns *core.Namespace) *NamespaceStuckError {
+	stuck := &NamespaceStuckError{Name: ns.Name}
+	for _, finalizer := range ns.Spec.Finalizers {
+		stuck.Finalizers = append(stuck.Finalizers, string(finalizer))
+	}
+	for _, cond := range ns.Status.Conditions {
+		if cond.Status == core.ConditionTrue {
+			stuck.Conditions = append(stuck.Conditions, fmt.Sprintf("%s: %s", cond.Type, cond.Message))
+		}
+	}
+	return stuck
+}
+
+// labelValue turns s into a valid label value: at most 63 characters out of
+// [A-Za-z0-9_.-], starting and ending with an alphanumeric character.
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "_")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "_.-")
 }
@@ -0,0 +1,99 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Namespace lifecycle", func() {
+	var f *Framework
+
+	BeforeEach(func() {
+		f = newFakeFramework()
+	})
+
+	It("creates the namespace with the run ID and deletes it", func() {
+		RunID = "e2e-run-1234"
+		DeferCleanup(func() { RunID = "" })
+
+		Expect(f.CreateNamespace()).To(Succeed())
+		ns, err := f.kubeClient.CoreV1().Namespaces().Get(context.TODO(), f.Namespace(), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ns.Labels).To(HaveKeyWithValue(runIDLabel, "e2e-run-1234"))
+
+		Expect(f.DeleteNamespace()).To(Succeed())
+		_, err = f.kubeClient.CoreV1().Namespaces().Get(context.TODO(), f.Namespace(), metav1.GetOptions{})
+		Expect(kerr.IsNotFound(err)).To(BeTrue())
+		Expect(f.DeleteNamespace()).To(Succeed())
+	})
+
+	It("times out when the namespace never becomes active", func() {
+		f.kubeClient = fake.NewSimpleClientset()
+
+		Expect(f.CreateNamespace()).To(MatchError(ContainSubstring("to become active")))
+	})
+
+	It("reports what keeps a namespace terminating", func() {
+		Expect(f.CreateNamespace()).To(Succeed())
+		client := f.kubeClient.(*fake.Clientset)
+		client.PrependReactor("delete", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			ns, err := client.Tracker().Get(core.SchemeGroupVersion.WithResource("namespaces"), "", f.Namespace())
+			Expect(err).NotTo(HaveOccurred())
+			terminating := ns.(*core.Namespace).DeepCopy()
+			terminating.Spec.Finalizers = []core.FinalizerName{core.FinalizerKubernetes}
+			terminating.Status.Phase = core.NamespaceTerminating
+			terminating.Status.Conditions = []core.NamespaceCondition{
+				{Type: core.NamespaceContentRemaining, Status: core.ConditionTrue, Message: "Some resources are remaining: persistentvolumeclaims. has 1 resource instances"},
+				{Type: core.NamespaceDeletionDiscoveryFailure, Status: core.ConditionFalse, Message: "All resources successfully discovered"},
+			}
+			return true, nil, client.Tracker().Update(core.SchemeGroupVersion.WithResource("namespaces"), terminating, "")
+		})
+
+		err := f.DeleteNamespace()
+
+		var stuck *NamespaceStuckError
+		Expect(errors.As(err, &stuck)).To(BeTrue())
+		Expect(stuck.Name).To(Equal(f.Namespace()))
+		Expect(stuck.Finalizers).To(ConsistOf("kubernetes"))
+		Expect(stuck.Conditions).To(ConsistOf(ContainSubstring("persistentvolumeclaims")))
+	})
+
+	It("deletes namespaces of earlier runs older than the TTL", func() {
+		RunID = "e2e-run-current"
+		DeferCleanup(func() { RunID = "" })
+		old := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		namespace := func(name, runID string, created metav1.Time) *core.Namespace {
+			return &core.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Labels:            map[string]string{runIDLabel: runID},
+				CreationTimestamp: created,
+			}}
+		}
+		f = newFakeFramework(
+			namespace("lke-stale", "e2e-run-old", old),
+			namespace("lke-fresh", "e2e-run-other", metav1.Now()),
+			namespace("lke-current", "e2e-run-current", old),
+			&core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", CreationTimestamp: old}},
+		)
+
+		Expect(f.DeleteStaleNamespaces(time.Hour)).To(Succeed())
+
+		list, err := f.kubeClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Items).To(ConsistOf(
+			HaveField("Name", "lke-fresh"),
+			HaveField("Name", "lke-current"),
+			HaveField("Name", "kube-system"),
+		))
+	})
+})
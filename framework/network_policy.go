@@ -37,8 +37,13 @@ func (i *k8sInvocation) GetNetworkPolicyObject(name string, labels map[string]st
 
 func (i *k8sInvocation) CreateNetworkPolicy(np *v1.NetworkPolicy) error {
 	_, err := i.kubeClient.NetworkingV1().NetworkPolicies(i.Namespace()).Create(context.TODO(), np, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	name := np.Name
+	i.tracker.track("NetworkPolicy", i.Namespace(), name, func() error { return i.DeleteNetworkPolicy(name) })
 
-	return err
+	return nil
 }
 
 func (i *k8sInvocation) DeleteNetworkPolicy(name string) error {
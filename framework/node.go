@@ -4,6 +4,7 @@ import (
 	"context"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
 )
 
@@ -33,3 +34,30 @@ func (i *k8sInvocation) GetNodeMetrics() (*v1beta1.NodeMetricsList, error) {
 	}
 	return metrics, nil
 }
+
+// CordonNode marks the node unschedulable, e.g. to force a pod onto another
+// worker. The node is uncordoned when the spec ends.
+func (i *Invocation) CordonNode(name string) error {
+	if err := i.setUnschedulable(name, true); err != nil {
+		return err
+	}
+	i.tracker.track("Cordon", "", name, func() error { return i.UncordonNode(name) })
+	return nil
+}
+
+func (i *Invocation) UncordonNode(name string) error {
+	return i.setUnschedulable(name, false)
+}
+
+func (i *Invocation) setUnschedulable(name string, unschedulable bool) error {
+	nodes := i.kubeClient.CoreV1().Nodes()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nodes.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		node.Spec.Unschedulable = unschedulable
+		_, err = nodes.Update(context.TODO(), node, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,28 @@
+package framework
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("CordonNode", func() {
+	It("uncordons the node when the spec ends", func() {
+		inv, err := newFakeFramework(&core.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}).Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		unschedulable := func() bool {
+			node, err := inv.kubeClient.CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			return node.Spec.Unschedulable
+		}
+
+		Expect(inv.CordonNode("node-1")).To(Succeed())
+		Expect(unschedulable()).To(BeTrue())
+
+		Expect(inv.Cleanup()).To(Succeed())
+		Expect(unschedulable()).To(BeFalse())
+	})
+})
@@ -0,0 +1,174 @@
+package framework
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	regionLabel = "topology.kubernetes.io/region"
+)
+
+// NodeBalancer is the NodeBalancer backing a LoadBalancer Service as the
+// Linode API reports it, with one config per Service port.
+type NodeBalancer struct {
+	ID                 int
+	Label              string
+	Region             string
+	Hostname           string
+	IPv4               string
+	ClientConnThrottle int
+	Tags               []string
+	Configs            []NodeBalancerConfig
+}
+
+// Config returns the config of port.
+func (nb *NodeBalancer) Config(port int) (*NodeBalancerConfig, error) {
+	for i := range nb.Configs {
+		if nb.Configs[i].Port == port {
+			return &nb.Configs[i], nil
+		}
+	}
+	return nil, errors.Errorf("NodeBalancer %s has no config for port %d", nb.Label, port)
+}
+
+func linodeAPI() *linodeClient {
+	return newLinodeClient(LinodeURL, ApiToken)
+}
+
+// GetNodeBalancer waits for the LoadBalancer ingress of the Service and looks
+// up the NodeBalancer with that IP through the Linode API.
+func (i *k8sInvocation) GetNodeBalancer(serviceName string) (*NodeBalancer, error) {
+	svc, err := i.GetServiceWithLoadBalancerStatus(serviceName, i.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	ip := nodeBalancerIngress(svc)
+	if ip == "" {
+		return nil, errors.Errorf("service %s/%s has no IPv4 ingress: %s", i.Namespace(), serviceName, describeServiceStatus(svc))
+	}
+
+	ctx := context.TODO()
+	nodeBalancers, err := linodeAPI().ListNodeBalancers(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing NodeBalancers")
+	}
+	for _, nb := range nodeBalancers {
+		if nb.IPv4 == ip {
+			return getNodeBalancer(ctx, nb)
+		}
+	}
+	return nil, errors.Errorf("no NodeBalancer with IP %s for service %s/%s", ip, i.Namespace(), serviceName)
+}
+
+// GetNodeBalancerByID looks up a NodeBalancer through the Linode API. It
+// returns nil without an error when the NodeBalancer does not exist.
+func GetNodeBalancerByID(id int) (*NodeBalancer, error) {
+	ctx := context.TODO()
+	nb, err := linodeAPI().GetNodeBalancer(ctx, id)
+	if isAPIStatus(err, 404) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return getNodeBalancer(ctx, *nb)
+}
+
+func getNodeBalancer(ctx context.Context, nb nodeBalancer) (*NodeBalancer, error) {
+	configs, err := linodeAPI().ListNodeBalancerConfigs(ctx, nb.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing configs of NodeBalancer %s", nb.Label)
+	}
+	return &NodeBalancer{
+		ID:                 nb.ID,
+		Label:              nb.Label,
+		Region:             nb.Region,
+		Hostname:           nb.Hostname,
+		IPv4:               nb.IPv4,
+		ClientConnThrottle: nb.ClientConnThrottle,
+		Tags:               nb.Tags,
+		Configs:            configs,
+	}, nil
+}
+
+// CreateNodeBalancer creates an empty NodeBalancer tagged with ResourceTags,
+// e.g. for a Service to adopt. It is deleted when the spec ends.
+func (i *k8sInvocation) CreateNodeBalancer(region string) (*NodeBalancer, error) {
+	nb, err := linodeAPI().CreateNodeBalancer(context.TODO(), nodeBalancerCreateOptions{
+		Region: region,
+		Tags:   ResourceTags(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating NodeBalancer")
+	}
+	id := nb.ID
+	i.tracker.track("NodeBalancer", "", nb.Label, func() error { return DeleteNodeBalancer(id) })
+
+	return &NodeBalancer{ID: nb.ID, Label: nb.Label, Region: nb.Region, Hostname: nb.Hostname, IPv4: nb.IPv4, Tags: nb.Tags}, nil
+}
+
+// DeleteNodeBalancer deletes a NodeBalancer, e.g. one a Service kept with
+// the preserve annotation. A NodeBalancer that is already gone is ignored.
+func DeleteNodeBalancer(id int) error {
+	err := linodeAPI().DeleteNodeBalancer(context.TODO(), id)
+	if isAPIStatus(err, 404) {
+		return nil
+	}
+	return err
+}
+
+// GetClusterRegion returns the Linode region of the nodes, as labelled by the CCM.
+func (i *Invocation) GetClusterRegion() (string, error) {
+	nodes, err := i.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes.Items {
+		if region := node.Labels[regionLabel]; region != "" {
+			return region, nil
+		}
+	}
+	return "", errors.Errorf("no node has the %s label", regionLabel)
+}
+
+// nodeBalancerIngress returns the IPv4 ingress of svc, NodeBalancers are
+// looked up by their IPv4 address.
+func nodeBalancerIngress(svc *core.Service) string {
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" && !strings.Contains(ingress.IP, ":") {
+			return ingress.IP
+		}
+	}
+	return ""
+}
+
+// proxyProtocolConfig makes nginx refuse connections that do not start with a
+// PROXY protocol header and answer with the client address of the header.
+const proxyProtocolConfig = `server {
+    listen 80 proxy_protocol;
+    location / {
+        default_type text/plain;
+        return 200 "proxy-protocol client $proxy_protocol_addr\n";
+    }
+}
+`
+
+// GetProxyProtocolPodObject returns an nginx pod behind a NodeBalancer with
+// the proxy protocol annotation. Requests through the NodeBalancer are
+// answered with "proxy-protocol client <address>", direct ones are dropped.
+func (i *k8sInvocation) GetProxyProtocolPodObject(podName string, labels map[string]string) *core.Pod {
+	pod := i.GetPodObject(podName, labels)
+	container := &pod.Spec.Containers[0]
+	container.Env = []core.EnvVar{{Name: "NGINX_CONF", Value: proxyProtocolConfig}}
+	container.Command = []string{"sh", "-c", `printf '%s' "$NGINX_CONF" > /etc/nginx/conf.d/default.conf && exec nginx -g 'daemon off;'`}
+	container.ReadinessProbe = &core.Probe{
+		Handler: core.Handler{TCPSocket: &core.TCPSocketAction{Port: intstr.FromInt(80)}},
+	}
+	return pod
+}
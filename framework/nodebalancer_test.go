@@ -0,0 +1,98 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("NodeBalancer lookup", func() {
+	var (
+		api *fakeLinodeAPI
+		inv *Invocation
+	)
+
+	BeforeEach(func() {
+		api = newFakeLinodeAPI()
+		DeferCleanup(api.Close)
+		DeferCleanup(func(url, token string) { LinodeURL, ApiToken = url, token }, LinodeURL, ApiToken)
+		LinodeURL, ApiToken = api.URL, "fake-token"
+
+		var err error
+		inv, err = newFakeFramework(&core.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{regionLabel: "eu-west"}},
+		}).Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("finds the NodeBalancer of a Service by its ingress IP", func() {
+		id := api.addNodeBalancer(nodeBalancer{Label: "ccm-abc", IPv4: "192.0.2.10", ClientConnThrottle: 5}, time.Now())
+		api.addNodeBalancer(nodeBalancer{Label: "other", IPv4: "192.0.2.11"}, time.Now())
+		api.addNodeBalancerConfig(id, NodeBalancerConfig{Port: 80, Protocol: "http", Check: "http", CheckPath: "/healthz"})
+		api.addNodeBalancerConfig(id, NodeBalancerConfig{Port: 443, Protocol: "https"})
+
+		svc, err := inv.Cluster.NewService("hello").Create()
+		Expect(err).NotTo(HaveOccurred())
+		svc.Status.LoadBalancer.Ingress = []core.LoadBalancerIngress{{IP: "2001:db8::1"}, {IP: "192.0.2.10"}}
+		_, err = inv.kubeClient.CoreV1().Services(svc.Namespace).UpdateStatus(context.TODO(), svc, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		nb, err := inv.Cluster.GetNodeBalancer("hello")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nb.ID).To(Equal(id))
+		Expect(nb.ClientConnThrottle).To(Equal(5))
+		Expect(nb.Configs).To(HaveLen(2))
+
+		config, err := nb.Config(80)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Check).To(Equal("http"))
+		Expect(config.CheckPath).To(Equal("/healthz"))
+		_, err = nb.Config(8080)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not look up NodeBalancers for Services without an IPv4 ingress", func() {
+		svc, err := inv.Cluster.NewService("hello").Create()
+		Expect(err).NotTo(HaveOccurred())
+		svc.Status.LoadBalancer.Ingress = []core.LoadBalancerIngress{{IP: "2001:db8::1"}}
+		_, err = inv.kubeClient.CoreV1().Services(svc.Namespace).UpdateStatus(context.TODO(), svc, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		api.addNodeBalancer(nodeBalancer{Label: "no-ipv4"}, time.Now())
+
+		_, err = inv.Cluster.GetNodeBalancer("hello")
+		Expect(err).To(MatchError(ContainSubstring("service " + inv.Namespace() + "/hello has no IPv4 ingress")))
+	})
+
+	It("builds backends that require the proxy protocol", func() {
+		pod := inv.Cluster.GetProxyProtocolPodObject("proxied", map[string]string{"app": "proxied"})
+		container := pod.Spec.Containers[0]
+		Expect(container.Env[0].Value).To(ContainSubstring("listen 80 proxy_protocol;"))
+		Expect(container.Command[2]).To(ContainSubstring("$NGINX_CONF"))
+		Expect(container.ReadinessProbe.TCPSocket.Port.IntValue()).To(Equal(80))
+	})
+
+	It("creates tagged NodeBalancers and deletes them with the spec", func() {
+		region, err := inv.GetClusterRegion()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(region).To(Equal("eu-west"))
+
+		nb, err := inv.Cluster.CreateNodeBalancer(region)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nb.Tags).To(ContainElement(E2ETag))
+
+		found, err := GetNodeBalancerByID(nb.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).NotTo(BeNil())
+
+		Expect(inv.Cleanup()).To(Succeed())
+
+		found, err = GetNodeBalancerByID(nb.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeNil())
+		Expect(DeleteNodeBalancer(nb.ID)).To(Succeed())
+	})
+})
@@ -2,10 +2,15 @@ package framework
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	core "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
 )
 
@@ -87,12 +92,16 @@ func (i *k8sInvocation) CreatePod(pod *core.Pod) error {
 	if err != nil {
 		return err
 	}
+	name := pod.Name
+	i.tracker.track("Pod", i.Namespace(), name, func() error { return i.DeletePod(name) })
+
 	return i.WaitForReady(pod.ObjectMeta)
 
 }
 
+// GetPodMetrics lists the metrics of the pods in the invocation namespace.
 func (i *k8sInvocation) GetPodMetrics() (*v1beta1.PodMetricsList, error) {
-	metrics, err := i.metricsClient.MetricsV1beta1().PodMetricses("default").List(context.TODO(), metav1.ListOptions{})
+	metrics, err := i.metricsClient.MetricsV1beta1().PodMetricses(i.Namespace()).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -107,15 +116,202 @@ func (i *k8sInvocation) GetPod(name, ns string) (*core.Pod, error) {
 	return i.kubeClient.CoreV1().Pods(ns).Get(context.TODO(), name, metav1.GetOptions{})
 }
 
+// WaitForReady waits up to Timeouts.PodReady for the pod to become Ready. It
+// fails fast with a *PodFailedError when the pod can't get there on its own,
+// e.g. a bad image or a crash loop. Both errors list the events of the pod.
 func (i *k8sInvocation) WaitForReady(meta metav1.ObjectMeta) error {
-	return wait.PollImmediate(i.RetryInterval, i.Timeout, func() (bool, error) {
-		pod, err := i.kubeClient.CoreV1().Pods(i.Namespace()).Get(context.TODO(), meta.Name, metav1.GetOptions{})
-		if pod == nil || err != nil {
+	return i.waitForReady(meta.Name, "")
+}
+
+// KillPod deletes the pod without a grace period, like a crashed node would
+// lose it.
+func (i *k8sInvocation) KillPod(name string) error {
+	grace := int64(0)
+	return i.kubeClient.CoreV1().Pods(i.Namespace()).Delete(context.TODO(), name, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+}
+
+// WaitForReplacement waits like WaitForReady for the pod that replaces pod,
+// e.g. the pod a StatefulSet recreates under the same name once pod was
+// killed.
+func (i *k8sInvocation) WaitForReplacement(pod *core.Pod) error {
+	return i.waitForReady(pod.Name, pod.UID)
+}
+
+// waitForReady waits for the pod called name, ignoring the pod with the UID
+// replaced.
+func (i *k8sInvocation) waitForReady(name string, replaced types.UID) error {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		failed   *PodFailedError
+		observed *core.Pod
+	)
+	// A pod that stays unschedulable is not updated again, so the last
+	// observed pod is checked every RetryInterval until its grace ran out.
+	go func() {
+		ticker := time.NewTicker(i.RetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			mu.Lock()
+			if observed != nil {
+				failed = podFailure(observed)
+			}
+			done := failed != nil
+			mu.Unlock()
+			if done {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	pod, err := waitForPod(ctx, i.kubeClient, "pod-ready", i.Timeouts.PodReady, i.Namespace(), name, func(pod *core.Pod) (bool, error) {
+		if pod == nil || (replaced != "" && pod.UID == replaced) {
 			return false, nil
 		}
-		if pod.Status.Phase == core.PodRunning {
-			return true, nil
+		mu.Lock()
+		defer mu.Unlock()
+		observed = pod
+		if failed = podFailure(pod); failed != nil {
+			return false, failed
 		}
-		return false, nil
+		return podReady(pod), nil
 	})
+	cancel()
+	mu.Lock()
+	defer mu.Unlock()
+	switch {
+	case err == nil:
+		return nil
+	case failed != nil:
+		failed.Events = i.describeEvents(i.Namespace(), "Pod", name)
+		return failed
+	case pod == nil:
+		return errors.Wrapf(err, "waiting for pod %s/%s to be created", i.Namespace(), name)
+	}
+	return errors.Errorf("pod %s/%s is not ready after %s: %s\n%s", i.Namespace(), name, i.Timeouts.PodReady,
+		describePodStatus(pod), i.describeEvents(i.Namespace(), "Pod", name))
+}
+
+// PodFailedError is returned when a pod is in a state it won't recover from
+// without intervention.
+type PodFailedError struct {
+	Namespace string
+	Name      string
+	// Reason is e.g. ImagePullBackOff, CrashLoopBackOff, Failed or
+	// Unschedulable.
+	Reason  string
+	Message string
+	// Events lists the recent events of the pod.
+	Events string
+}
+
+func (e *PodFailedError) Error() string {
+	msg := fmt.Sprintf("pod %s/%s failed: %s", e.Namespace, e.Name, e.Reason)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Events != "" {
+		msg += "\n" + e.Events
+	}
+	return msg
+}
+
+// terminalWaitingReasons are container waiting reasons that don't resolve by
+// waiting longer.
+var terminalWaitingReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"CrashLoopBackOff":           true,
+}
+
+// unschedulableGrace is how long a pod has to stay unschedulable before the
+// wait for it fails, long enough for the cluster autoscaler to add a node.
+var unschedulableGrace = 3 * time.Minute
+
+// unboundClaimsMessage is the scheduler message of pods whose claims are still
+// being provisioned, which resolves by itself.
+const unboundClaimsMessage = "unbound immediate PersistentVolumeClaims"
+
+func podReady(pod *core.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == core.PodReady {
+			return c.Status == core.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podFailure returns why pod can't become ready, or nil if it still can.
+func podFailure(pod *core.Pod) *PodFailedError {
+	failure := func(reason, message string) *PodFailedError {
+		return &PodFailedError{Namespace: pod.Namespace, Name: pod.Name, Reason: reason, Message: message}
+	}
+
+	switch pod.Status.Phase {
+	case core.PodFailed:
+		reason := pod.Status.Reason
+		if reason == "" {
+			reason = string(core.PodFailed)
+		}
+		return failure(reason, pod.Status.Message)
+	case core.PodSucceeded:
+		return failure(string(core.PodSucceeded), "all containers exited")
+	}
+
+	for _, c := range pod.Status.Conditions {
+		if c.Type == core.PodScheduled && c.Status == core.ConditionFalse && c.Reason == core.PodReasonUnschedulable &&
+			!strings.Contains(c.Message, unboundClaimsMessage) && time.Since(c.LastTransitionTime.Time) >= unschedulableGrace {
+			return failure(c.Reason, c.Message)
+		}
+	}
+
+	statuses := append(append([]core.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		waiting := status.State.Waiting
+		if waiting == nil || !terminalWaitingReasons[waiting.Reason] {
+			continue
+		}
+		message := fmt.Sprintf("container %s: %s", status.Name, waiting.Message)
+		if waiting.Reason == "CrashLoopBackOff" {
+			message = fmt.Sprintf("container %s restarted %d times", status.Name, status.RestartCount)
+			if last := status.LastTerminationState.Terminated; last != nil {
+				message += fmt.Sprintf(", last exit code %d (%s)", last.ExitCode, last.Reason)
+			}
+		}
+		return failure(waiting.Reason, message)
+	}
+	return nil
+}
+
+// describePodStatus summarizes the phase, conditions and containers of a pod
+// that is not ready.
+func describePodStatus(pod *core.Pod) string {
+	parts := []string{"phase " + string(pod.Status.Phase)}
+	for _, c := range pod.Status.Conditions {
+		if c.Status != core.ConditionTrue {
+			parts = append(parts, strings.TrimSpace(fmt.Sprintf("%s=%s %s %s", c.Type, c.Status, c.Reason, c.Message)))
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		state := "running"
+		switch {
+		case status.State.Waiting != nil:
+			state = "waiting: " + status.State.Waiting.Reason
+		case status.State.Terminated != nil:
+			state = "terminated: " + status.State.Terminated.Reason
+		}
+		parts = append(parts, fmt.Sprintf("container %s %s, ready %t, %d restarts", status.Name, state, status.Ready, status.RestartCount))
+	}
+	return strings.Join(parts, "; ")
 }
@@ -0,0 +1,211 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("WaitForReady", func() {
+	var inv *Invocation
+
+	BeforeEach(func() {
+		var err error
+		inv, err = newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	createPod := func(status core.PodStatus) metav1.ObjectMeta {
+		pod := &core.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: inv.Namespace()}, Status: status}
+		_, err := inv.kubeClient.CoreV1().Pods(inv.Namespace()).Create(context.TODO(), pod, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return pod.ObjectMeta
+	}
+
+	createEvent := func(reason, message string) {
+		_, err := inv.kubeClient.CoreV1().Events(inv.Namespace()).Create(context.TODO(), &core.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "web." + reason},
+			InvolvedObject: core.ObjectReference{Kind: "Pod", Name: "web"},
+			Type:           core.EventTypeWarning,
+			Reason:         reason,
+			Message:        message,
+		}, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	ready := func(status core.ConditionStatus) core.PodCondition {
+		return core.PodCondition{Type: core.PodReady, Status: status}
+	}
+
+	It("waits for the Ready condition rather than the Running phase", func() {
+		meta := createPod(core.PodStatus{Phase: core.PodRunning, Conditions: []core.PodCondition{ready(core.ConditionFalse)}})
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(300 * time.Millisecond)
+			pod, err := inv.kubeClient.CoreV1().Pods(inv.Namespace()).Get(context.TODO(), "web", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			pod.Status.Conditions = []core.PodCondition{ready(core.ConditionTrue)}
+			_, err = inv.kubeClient.CoreV1().Pods(inv.Namespace()).UpdateStatus(context.TODO(), pod, metav1.UpdateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}()
+
+		start := time.Now()
+		Expect(inv.Cluster.WaitForReady(meta)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	DescribeTable("fails fast on terminal states",
+		func(status core.PodStatus, reason string, message string) {
+			meta := createPod(status)
+			createEvent("Failing", "something went wrong")
+
+			start := time.Now()
+			err := inv.Cluster.WaitForReady(meta)
+			Expect(time.Since(start)).To(BeNumerically("<", inv.Timeouts.PodReady))
+
+			var failed *PodFailedError
+			Expect(errors.As(err, &failed)).To(BeTrue())
+			Expect(failed.Reason).To(Equal(reason))
+			Expect(failed.Message).To(ContainSubstring(message))
+			Expect(err).To(MatchError(ContainSubstring("something went wrong")))
+		},
+		Entry("image pull errors", core.PodStatus{
+			Phase: core.PodPending,
+			ContainerStatuses: []core.ContainerStatus{{
+				Name:  "nginx",
+				State: core.ContainerState{Waiting: &core.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: `Back-off pulling image "nginx:nope"`}},
+			}},
+		}, "ImagePullBackOff", `container nginx: Back-off pulling image "nginx:nope"`),
+		Entry("crash loops", core.PodStatus{
+			Phase: core.PodRunning,
+			ContainerStatuses: []core.ContainerStatus{{
+				Name:                 "nginx",
+				RestartCount:         4,
+				State:                core.ContainerState{Waiting: &core.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: core.ContainerState{Terminated: &core.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+			}},
+		}, "CrashLoopBackOff", "container nginx restarted 4 times, last exit code 1 (Error)"),
+		Entry("crash looping init containers", core.PodStatus{
+			Phase: core.PodPending,
+			InitContainerStatuses: []core.ContainerStatus{{
+				Name:         "init",
+				RestartCount: 2,
+				State:        core.ContainerState{Waiting: &core.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			}},
+		}, "CrashLoopBackOff", "container init restarted 2 times"),
+		Entry("failed pods", core.PodStatus{
+			Phase:   core.PodFailed,
+			Reason:  "Evicted",
+			Message: "The node was low on resource: memory.",
+		}, "Evicted", "low on resource"),
+		Entry("unschedulable pods", core.PodStatus{
+			Phase: core.PodPending,
+			Conditions: []core.PodCondition{{
+				Type:               core.PodScheduled,
+				Status:             core.ConditionFalse,
+				Reason:             core.PodReasonUnschedulable,
+				Message:            "0/2 nodes are available: 2 Insufficient cpu.",
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-unschedulableGrace)),
+			}},
+		}, "Unschedulable", "Insufficient cpu"),
+	)
+
+	DescribeTable("waits out transient scheduling failures",
+		func(message string, since time.Duration) {
+			inv.Timeouts.PodReady = 200 * time.Millisecond
+			meta := createPod(core.PodStatus{
+				Phase: core.PodPending,
+				Conditions: []core.PodCondition{{
+					Type:               core.PodScheduled,
+					Status:             core.ConditionFalse,
+					Reason:             core.PodReasonUnschedulable,
+					Message:            message,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+				}},
+			})
+
+			err := inv.Cluster.WaitForReady(meta)
+			var failed *PodFailedError
+			Expect(errors.As(err, &failed)).To(BeFalse())
+			Expect(err).To(MatchError(ContainSubstring("is not ready after 200ms")))
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("claims being provisioned", "0/2 nodes are available: pod has unbound immediate PersistentVolumeClaims.", time.Hour),
+		Entry("a scale-up in progress", "0/2 nodes are available: 2 Insufficient cpu.", time.Minute),
+	)
+
+	It("fails once a pod stays unschedulable for the grace period", func() {
+		defer func(grace time.Duration) { unschedulableGrace = grace }(unschedulableGrace)
+		unschedulableGrace = 300 * time.Millisecond
+		inv.Timeouts.PodReady = 5 * time.Second
+		meta := createPod(core.PodStatus{
+			Phase: core.PodPending,
+			Conditions: []core.PodCondition{{
+				Type:               core.PodScheduled,
+				Status:             core.ConditionFalse,
+				Reason:             core.PodReasonUnschedulable,
+				Message:            "0/2 nodes are available: 2 Insufficient cpu.",
+				LastTransitionTime: metav1.Now(),
+			}},
+		})
+
+		start := time.Now()
+		err := inv.Cluster.WaitForReady(meta)
+		Expect(time.Since(start)).To(And(
+			BeNumerically(">=", 300*time.Millisecond),
+			BeNumerically("<", inv.Timeouts.PodReady),
+		))
+		var failed *PodFailedError
+		Expect(errors.As(err, &failed)).To(BeTrue())
+		Expect(failed.Reason).To(Equal("Unschedulable"))
+		Expect(failed.Message).To(ContainSubstring("Insufficient cpu"))
+	})
+
+	It("describes the pod and its events on timeout", func() {
+		inv.Timeouts.PodReady = 200 * time.Millisecond
+		meta := createPod(core.PodStatus{
+			Phase:      core.PodRunning,
+			Conditions: []core.PodCondition{{Type: core.PodReady, Status: core.ConditionFalse, Reason: "ContainersNotReady"}},
+			ContainerStatuses: []core.ContainerStatus{{
+				Name:  "nginx",
+				State: core.ContainerState{Running: &core.ContainerStateRunning{}},
+			}},
+		})
+		createEvent("Unhealthy", "Readiness probe failed: HTTP probe failed with statuscode: 503")
+
+		err := inv.Cluster.WaitForReady(meta)
+		Expect(err).To(MatchError(ContainSubstring("is not ready after 200ms")))
+		Expect(err).To(MatchError(ContainSubstring("Ready=False ContainersNotReady")))
+		Expect(err).To(MatchError(ContainSubstring("container nginx running, ready false, 0 restarts")))
+		Expect(err).To(MatchError(ContainSubstring("Readiness probe failed")))
+	})
+})
+
+var _ = Describe("WaitForReplacement", func() {
+	It("ignores the pod that was killed", func() {
+		inv, err := newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		pods := inv.kubeClient.CoreV1().Pods(inv.Namespace())
+		ready := core.PodStatus{Conditions: []core.PodCondition{{Type: core.PodReady, Status: core.ConditionTrue}}}
+
+		old, err := pods.Create(context.TODO(), &core.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", UID: "old"}, Status: ready}, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Cluster.KillPod("db-0")).To(Succeed())
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(300 * time.Millisecond)
+			_, err := pods.Create(context.TODO(), &core.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", UID: "new"}, Status: ready}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}()
+
+		start := time.Now()
+		Expect(inv.Cluster.WaitForReplacement(old)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+})
@@ -0,0 +1,57 @@
+package framework
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// retriesEntry names the report entries recording the attempts of wait loops.
+const retriesEntry = "Retries"
+
+// RetryCount records how often a wait loop polled, or how many states of the
+// watched object it observed, before it finished.
+type RetryCount struct {
+	Operation       string  `json:"operation"`
+	Attempts        int     `json:"attempts"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	TimedOut        bool    `json:"timedOut,omitempty"`
+}
+
+func (r RetryCount) String() string {
+	s := fmt.Sprintf("%s: %d attempts in %.1fs", r.Operation, r.Attempts, r.DurationSeconds)
+	if r.TimedOut {
+		s += " (timed out)"
+	}
+	return s
+}
+
+// poll is wait.PollImmediate that records the number of attempts on the
+// report of the current spec, see WriteReports.
+func poll(operation string, interval, timeout time.Duration, condition wait.ConditionFunc) error {
+	attempts := 0
+	start := time.Now()
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		attempts++
+		return condition()
+	})
+	recordRetries(RetryCount{
+		Operation:       operation,
+		Attempts:        attempts,
+		DurationSeconds: time.Since(start).Seconds(),
+		TimedOut:        err == wait.ErrWaitTimeout,
+	})
+	return err
+}
+
+func recordRetries(r RetryCount) {
+	// Report entries can only be added while ginkgo runs a node, the
+	// provisioners and the sweeper also poll outside of a suite.
+	if ginkgo.CurrentSpecReport().LeafNodeType == types.NodeTypeInvalid {
+		return
+	}
+	ginkgo.AddReportEntry(retriesEntry, r, ginkgo.ReportEntryVisibilityNever)
+}
@@ -0,0 +1,133 @@
+package framework
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+var (
+	// PostgresCatalog is the manifest of the PostgresVersions the database
+	// specs can run, DBCatalogName picks one.
+	PostgresCatalog = "manifest/pg-version.yaml"
+	// UseKubeDBImage runs the DB image of the catalog from DockerRegistry
+	// instead of the upstream Postgres image.
+	UseKubeDBImage = false
+)
+
+const (
+	postgresPort     = 5432
+	postgresDataPath = "/var/lib/postgresql/data"
+	postgresPassword = "e2e-postgres"
+)
+
+// PostgresVersion is a KubeDB PostgresVersion catalog entry.
+type PostgresVersion struct {
+	Name string
+	// Version is the Postgres version, e.g. 9.6.
+	Version string
+	// DBImage is the image KubeDB runs for the version, e.g.
+	// kubedb/postgres:9.6-v4. KubeDB configures it through its operator.
+	DBImage string
+}
+
+// Image returns the upstream postgres:Version image, which runs as a plain
+// StatefulSet. With UseKubeDBImage it returns DBImage pulled from
+// DockerRegistry instead, e.g. kubedbci/postgres:9.6-v4.
+func (v *PostgresVersion) Image() string {
+	if !UseKubeDBImage || v.DBImage == "" {
+		return "docker.io/library/postgres:" + v.Version
+	}
+	return strings.TrimSuffix(DockerRegistry, "/") + "/" + v.DBImage[strings.LastIndex(v.DBImage, "/")+1:]
+}
+
+// LoadPostgresVersion reads the PostgresVersion called name from the
+// manifest, which may hold several YAML documents.
+func LoadPostgresVersion(manifestPath, name string) (*PostgresVersion, error) {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		var doc struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Version string `json:"version"`
+				DB      struct {
+					Image string `json:"image"`
+				} `json:"db"`
+			} `json:"spec"`
+		}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", manifestPath)
+		}
+		if doc.Kind == "PostgresVersion" && doc.Metadata.Name == name {
+			return &PostgresVersion{Name: name, Version: doc.Spec.Version, DBImage: doc.Spec.DB.Image}, nil
+		}
+	}
+	return nil, errors.Errorf("no PostgresVersion %s in %s", name, manifestPath)
+}
+
+// CreatePostgres runs Postgres as a single replica StatefulSet whose data
+// lives on a claim of size from StorageClass. The StatefulSet and the claim
+// are deleted when the spec ends. Wait for the database with WaitForRollout.
+func (i *k8sInvocation) CreatePostgres(name string, version *PostgresVersion, size string) (*apps.StatefulSet, error) {
+	claim := i.GetPersistentVolumeClaimObject("data", size)
+	claim.Namespace = ""
+
+	return i.NewWorkload(name, map[string]string{"app": name}).
+		WithImage(version.Image()).
+		WithPodSpec(func(spec *core.PodSpec) {
+			container := &spec.Containers[0]
+			container.Name = "postgres"
+			container.Ports = []core.ContainerPort{{Name: "postgres", ContainerPort: postgresPort}}
+			container.Env = []core.EnvVar{
+				{Name: "POSTGRES_PASSWORD", Value: postgresPassword},
+				// The root of a fresh volume holds lost+found, which
+				// initdb refuses.
+				{Name: "PGDATA", Value: postgresDataPath + "/pgdata"},
+			}
+			container.ReadinessProbe = &core.Probe{
+				Handler: core.Handler{
+					Exec: &core.ExecAction{Command: []string{"pg_isready", "-U", "postgres", "-h", "127.0.0.1"}},
+				},
+				PeriodSeconds: 5,
+			}
+			container.LivenessProbe = &core.Probe{
+				Handler: core.Handler{
+					TCPSocket: &core.TCPSocketAction{Port: intstr.FromInt(postgresPort)},
+				},
+				InitialDelaySeconds: 30,
+			}
+		}).
+		WithVolumeClaimTemplate(*claim, postgresDataPath).
+		CreateStatefulSet()
+}
+
+// PostgresPodName returns the pod of the database created by CreatePostgres.
+func PostgresPodName(name string) string {
+	return name + "-0"
+}
+
+// Psql runs the SQL statements in the database pod and returns the unaligned
+// rows. It stops at the first failing statement.
+func (i *k8sInvocation) Psql(podName, sql string) (string, error) {
+	out, err := i.ExecInPod(podName, "psql", "-U", "postgres", "-v", "ON_ERROR_STOP=1", "-qtAc", sql)
+	return strings.TrimSpace(out), err
+}
@@ -0,0 +1,70 @@
+package framework
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Postgres", func() {
+	It("reads PostgresVersions from the catalog manifest", func() {
+		version, err := LoadPostgresVersion(filepath.Join("..", PostgresCatalog), DBCatalogName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version.Version).To(Equal("9.6"))
+		Expect(version.DBImage).To(Equal("kubedb/postgres:9.6-v4"))
+		Expect(version.Image()).To(Equal("docker.io/library/postgres:9.6"))
+
+		defer func() { UseKubeDBImage = false }()
+		UseKubeDBImage = true
+		Expect(version.Image()).To(Equal("kubedbci/postgres:9.6-v4"))
+
+		manifest := filepath.Join(GinkgoT().TempDir(), "catalog.yaml")
+		Expect(ioutil.WriteFile(manifest, []byte(`apiVersion: catalog.kubedb.com/v1alpha1
+kind: PostgresVersion
+metadata:
+  name: "9.6-v2"
+spec:
+  version: "9.6"
+---
+apiVersion: catalog.kubedb.com/v1alpha1
+kind: PostgresVersion
+metadata:
+  name: "13.2"
+spec:
+  version: "13.2"
+`), 0644)).To(Succeed())
+		version, err = LoadPostgresVersion(manifest, "13.2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version.Version).To(Equal("13.2"))
+
+		_, err = LoadPostgresVersion(manifest, "8.4")
+		Expect(err).To(MatchError(ContainSubstring("no PostgresVersion 8.4")))
+	})
+
+	It("runs Postgres as a StatefulSet on a claim", func() {
+		inv, err := newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+
+		sts, err := inv.Cluster.CreatePostgres("db", &PostgresVersion{Name: "13.2", Version: "13.2"}, "10Gi")
+		Expect(err).NotTo(HaveOccurred())
+		container := sts.Spec.Template.Spec.Containers[0]
+		Expect(container.Image).To(Equal("docker.io/library/postgres:13.2"))
+		Expect(container.Ports[0].ContainerPort).To(BeEquivalentTo(5432))
+		Expect(container.Env).To(ContainElement(HaveField("Name", "PGDATA")))
+		Expect(container.VolumeMounts).To(ConsistOf(HaveField("MountPath", postgresDataPath)))
+		Expect(sts.Spec.VolumeClaimTemplates[0].Namespace).To(BeEmpty())
+		Expect(*sts.Spec.VolumeClaimTemplates[0].Spec.StorageClassName).To(Equal(StorageClass))
+
+		Expect(inv.Resources()).To(ConsistOf(
+			HaveField("String()", "PersistentVolumeClaim "+inv.Namespace()+"/data-db-0"),
+			HaveField("String()", "StatefulSet "+inv.Namespace()+"/db"),
+		))
+		Expect(inv.Cleanup()).To(Succeed())
+		_, err = inv.kubeClient.AppsV1().StatefulSets(inv.Namespace()).Get(context.TODO(), "db", metav1.GetOptions{})
+		Expect(isNotFound(err)).To(BeTrue())
+	})
+})
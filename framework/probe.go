@@ -0,0 +1,265 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	defaultProbeTimeout = 10 * time.Second
+	// maxProbeBody caps how much of a response body is read and matched.
+	maxProbeBody = 1 << 20
+)
+
+// Probe is an HTTP request and what its response has to look like. Build it
+// with NewProbe and the With*/Expect* methods:
+//
+//	probe := framework.NewProbe(url).
+//		WithHost("shop.example.com").
+//		ExpectStatus(200, 301).
+//		ExpectBodyContains("Hello world").
+//		ExpectConsecutiveSuccesses(3)
+//	Eventually(probe.Check).Should(Succeed())
+//
+// A Probe counts consecutive successes across calls of Check, so use a new
+// Probe for every wait.
+type Probe struct {
+	url          string
+	method       string
+	timeout      time.Duration
+	headers      http.Header
+	host         string
+	client       *http.Client
+	statusCodes  []int
+	contains     []string
+	patterns     []*regexp.Regexp
+	jsonPaths    []jsonPathMatch
+	requiredRuns int
+
+	mu        sync.Mutex
+	successes int
+}
+
+type jsonPathMatch struct {
+	path  string
+	value string
+}
+
+// ProbeResult is a response received by a Probe.
+type ProbeResult struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+	Duration   time.Duration
+}
+
+// NewProbe probes url with GET, expecting a 200 within 10s.
+func NewProbe(url string) *Probe {
+	return &Probe{
+		url:          url,
+		method:       http.MethodGet,
+		timeout:      defaultProbeTimeout,
+		headers:      http.Header{},
+		statusCodes:  []int{http.StatusOK},
+		requiredRuns: 1,
+	}
+}
+
+func (p *Probe) WithMethod(method string) *Probe {
+	p.method = method
+	return p
+}
+
+// WithTimeout bounds every single request, so a blackholed load balancer
+// fails the attempt instead of hanging the spec.
+func (p *Probe) WithTimeout(timeout time.Duration) *Probe {
+	p.timeout = timeout
+	return p
+}
+
+func (p *Probe) WithHeader(key, value string) *Probe {
+	p.headers.Add(key, value)
+	return p
+}
+
+// WithHost overrides the Host header, e.g. to reach a virtual host through
+// the IP of a load balancer.
+func (p *Probe) WithHost(host string) *Probe {
+	p.host = host
+	return p
+}
+
+// WithClient sends the requests with client, e.g. CertificateAuthority.Client.
+// The probe timeout still applies.
+func (p *Probe) WithClient(client *http.Client) *Probe {
+	p.client = client
+	return p
+}
+
+// ExpectStatus replaces the accepted status codes.
+func (p *Probe) ExpectStatus(codes ...int) *Probe {
+	p.statusCodes = codes
+	return p
+}
+
+func (p *Probe) ExpectBodyContains(substrings ...string) *Probe {
+	p.contains = append(p.contains, substrings...)
+	return p
+}
+
+// ExpectBodyMatches expects the body to match the regular expression. It
+// panics on an invalid expression, like regexp.MustCompile.
+func (p *Probe) ExpectBodyMatches(pattern string) *Probe {
+	p.patterns = append(p.patterns, regexp.MustCompile(pattern))
+	return p
+}
+
+// ExpectJSONPath expects the body to be JSON in which the kubectl style
+// JSONPath template, e.g. "{.status}", prints value.
+func (p *Probe) ExpectJSONPath(path, value string) *Probe {
+	p.jsonPaths = append(p.jsonPaths, jsonPathMatch{path: path, value: value})
+	return p
+}
+
+// ExpectConsecutiveSuccesses makes Check succeed only after n successful
+// responses in a row, to ride out flapping backends.
+func (p *Probe) ExpectConsecutiveSuccesses(n int) *Probe {
+	p.requiredRuns = n
+	return p
+}
+
+// Do sends one request and returns an error if the response does not match.
+// The result is returned whenever a response was received.
+func (p *Probe) Do() (*ProbeResult, error) {
+	req, err := http.NewRequest(p.method, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range p.headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if p.host != "" {
+		req.Host = p.host
+	}
+
+	client := &http.Client{}
+	if p.client != nil {
+		c := *p.client
+		client = &c
+	}
+	client.Timeout = p.timeout
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	result := &ProbeResult{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       string(body),
+		Duration:   time.Since(start),
+	}
+	if err != nil {
+		return result, errors.Wrapf(err, "reading response of %s", p.url)
+	}
+
+	return result, p.match(result)
+}
+
+// Check is Do for Eventually: it succeeds once the expected number of
+// consecutive responses matched.
+func (p *Probe) Check() error {
+	_, err := p.Do()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.successes = 0
+		return err
+	}
+	p.successes++
+	if p.successes < p.requiredRuns {
+		return errors.Errorf("%s: %d of %d consecutive successes", p.url, p.successes, p.requiredRuns)
+	}
+	return nil
+}
+
+func (p *Probe) match(result *ProbeResult) error {
+	statusOK := false
+	for _, code := range p.statusCodes {
+		statusOK = statusOK || result.StatusCode == code
+	}
+	if !statusOK {
+		return errors.Errorf("%s answered %d, expected one of %v", p.url, result.StatusCode, p.statusCodes)
+	}
+
+	for _, s := range p.contains {
+		if !strings.Contains(result.Body, s) {
+			return errors.Errorf("response of %s does not contain %q", p.url, s)
+		}
+	}
+	for _, re := range p.patterns {
+		if !re.MatchString(result.Body) {
+			return errors.Errorf("response of %s does not match %q", p.url, re)
+		}
+	}
+
+	if len(p.jsonPaths) == 0 {
+		return nil
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(result.Body), &data); err != nil {
+		return errors.Wrapf(err, "response of %s is not JSON", p.url)
+	}
+	for _, m := range p.jsonPaths {
+		got, err := evalJSONPath(m.path, data)
+		if err != nil {
+			return errors.Wrapf(err, "response of %s", p.url)
+		}
+		if got != m.value {
+			return errors.Errorf("response of %s has %s = %q, expected %q", p.url, m.path, got, m.value)
+		}
+	}
+	return nil
+}
+
+func evalJSONPath(path string, data interface{}) (string, error) {
+	j := jsonpath.New("probe")
+	if err := j.Parse(path); err != nil {
+		return "", errors.Wrapf(err, "parsing JSONPath %s", path)
+	}
+	var buf bytes.Buffer
+	if err := j.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "evaluating JSONPath %s", path)
+	}
+	return buf.String(), nil
+}
+
+// WaitForProbe waits until the probe succeeds and returns its last error on
+// timeout.
+func (i *Invocation) WaitForProbe(p *Probe) error {
+	var lastErr error
+	err := poll("http-probe", i.RetryInterval, i.Timeout, func() (bool, error) {
+		lastErr = p.Check()
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return errors.Wrapf(lastErr, "waiting for %s", p.url)
+	}
+	return nil
+}
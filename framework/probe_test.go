@@ -0,0 +1,137 @@
+package framework
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Probe", func() {
+	var (
+		server   *httptest.Server
+		requests int32
+		// failures is how many requests fail with 503 before the server is up.
+		failures int32
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&failures, 0)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= atomic.LoadInt32(&failures) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(w, "Hello world from %s, build 1234", r.Host)
+		})
+		mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"status": "ok", "backends": [{"name": "a", "up": true}]}`)
+		})
+		mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s", r.Method, r.Header.Get("X-Probe"))
+		})
+		mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		})
+		server = httptest.NewServer(mux)
+		DeferCleanup(server.Close)
+	})
+
+	It("expects a 200 by default", func() {
+		result, err := NewProbe(server.URL).Do()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.StatusCode).To(Equal(http.StatusOK))
+
+		_, err = NewProbe(server.URL + "/moved").Do()
+		Expect(err).To(MatchError(ContainSubstring("answered 204")))
+		_, err = NewProbe(server.URL+"/moved").ExpectStatus(http.StatusOK, http.StatusNoContent).Do()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("matches substrings and regular expressions", func() {
+		_, err := NewProbe(server.URL).ExpectBodyContains("Hello world").ExpectBodyMatches(`build \d+`).Do()
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewProbe(server.URL).ExpectBodyContains("Goodbye").Do()
+		Expect(err).To(MatchError(ContainSubstring(`does not contain "Goodbye"`)))
+		_, err = NewProbe(server.URL).ExpectBodyMatches(`^build`).Do()
+		Expect(err).To(MatchError(ContainSubstring(`does not match "^build"`)))
+	})
+
+	It("matches JSONPath values", func() {
+		p := NewProbe(server.URL+"/status").
+			ExpectJSONPath("{.status}", "ok").
+			ExpectJSONPath("{.backends[0].up}", "true")
+		_, err := p.Do()
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewProbe(server.URL+"/status").ExpectJSONPath("{.status}", "degraded").Do()
+		Expect(err).To(MatchError(ContainSubstring(`{.status} = "ok"`)))
+		_, err = NewProbe(server.URL).ExpectJSONPath("{.status}", "ok").Do()
+		Expect(err).To(MatchError(ContainSubstring("not JSON")))
+	})
+
+	It("sends the method, headers and Host", func() {
+		result, err := NewProbe(server.URL + "/echo").WithMethod(http.MethodHead).Do()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Body).To(BeEmpty())
+
+		result, err = NewProbe(server.URL+"/echo").WithMethod(http.MethodPost).WithHeader("X-Probe", "e2e").Do()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Body).To(Equal("POST e2e"))
+
+		result, err = NewProbe(server.URL).WithHost("shop.example.com").Do()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Body).To(ContainSubstring("from shop.example.com"))
+	})
+
+	It("times out single requests", func() {
+		_, err := NewProbe(server.URL + "/slow").WithTimeout(20 * time.Millisecond).Do()
+		Expect(err).To(MatchError(ContainSubstring("Client.Timeout")))
+	})
+
+	It("counts consecutive successes and starts over on a failure", func() {
+		p := NewProbe(server.URL).ExpectConsecutiveSuccesses(2)
+		Expect(p.Check()).To(MatchError(ContainSubstring("1 of 2 consecutive successes")))
+		Expect(p.Check()).To(Succeed())
+
+		atomic.StoreInt32(&failures, atomic.LoadInt32(&requests)+1)
+		Expect(p.Check()).To(MatchError(ContainSubstring("answered 503")))
+		Expect(p.Check()).To(MatchError(ContainSubstring("1 of 2 consecutive successes")))
+		Expect(p.Check()).To(Succeed())
+	})
+
+	It("waits for the probe and reports its last error", func() {
+		inv, err := newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+
+		atomic.StoreInt32(&failures, 3)
+		Expect(inv.WaitForProbe(NewProbe(server.URL).ExpectConsecutiveSuccesses(2))).To(Succeed())
+		Expect(atomic.LoadInt32(&requests)).To(BeEquivalentTo(5))
+
+		err = inv.WaitForProbe(NewProbe(server.URL).ExpectBodyContains("Goodbye"))
+		Expect(err).To(MatchError(ContainSubstring(`does not contain "Goodbye"`)))
+	})
+
+	It("keeps the WordPress check of WaitForHTTPResponse", func() {
+		inv, err := newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.WaitForHTTPResponse(server.URL)).To(Succeed())
+
+		ok, body, err := GetHTTPResponse(server.URL + "/moved")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		Expect(body).To(BeEmpty())
+	})
+})
@@ -0,0 +1,23 @@
+package framework
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClusterNotCreated is returned by a ClusterProvisioner when it is asked
+// about a cluster before Create has succeeded.
+var ErrClusterNotCreated = errors.New("cluster has not been created")
+
+// ClusterProvisioner creates and destroys the cluster the suite runs against.
+type ClusterProvisioner interface {
+	// Create provisions a cluster shaped like spec and blocks until it is ready.
+	Create(ctx context.Context, spec ClusterSpec) error
+	// Kubeconfig returns the path of a kubeconfig file for the created cluster.
+	Kubeconfig(ctx context.Context) (string, error)
+	// Delete destroys the created cluster.
+	Delete(ctx context.Context) error
+	// Describe returns a human readable description of the cluster source.
+	Describe() string
+}
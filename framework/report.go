@@ -0,0 +1,193 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/reporters"
+	"github.com/onsi/ginkgo/v2/types"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// ReportDir is where WriteReports puts the JUnit and JSON reports of a
+	// run. Reports are disabled when it is empty.
+	ReportDir = "reports"
+)
+
+const (
+	junitReportFile = "junit.xml"
+	jsonReportFile  = "report.json"
+
+	byStepEntry = "By Step"
+)
+
+// ClusterMetadata describes the cluster a run tested against.
+type ClusterMetadata struct {
+	Provider          string `json:"provider"`
+	Name              string `json:"name,omitempty"`
+	KubernetesVersion string `json:"kubernetesVersion"`
+	NodeCount         int    `json:"nodeCount"`
+	CCMImage          string `json:"ccmImage"`
+	// CCMImageDigest is set when the suite deployed CCMImage itself.
+	CCMImageDigest string `json:"ccmImageDigest,omitempty"`
+}
+
+// SuiteSummary is the JSON report of a run, meant for CI dashboards.
+type SuiteSummary struct {
+	RunID           string          `json:"runID"`
+	Suite           string          `json:"suite"`
+	Passed          bool            `json:"passed"`
+	StartTime       time.Time       `json:"startTime"`
+	DurationSeconds float64         `json:"durationSeconds"`
+	Cluster         ClusterMetadata `json:"cluster"`
+	Specs           []SpecSummary   `json:"specs"`
+}
+
+type SpecSummary struct {
+	Name            string        `json:"name"`
+	Type            string        `json:"type"`
+	State           string        `json:"state"`
+	DurationSeconds float64       `json:"durationSeconds"`
+	Failure         string        `json:"failure,omitempty"`
+	Steps           []StepSummary `json:"steps,omitempty"`
+	Retries         []RetryCount  `json:"retries,omitempty"`
+}
+
+// StepSummary is a By() step of a spec. A step lasts until the next step or
+// the end of the spec.
+type StepSummary struct {
+	Text            string    `json:"text"`
+	StartTime       time.Time `json:"startTime"`
+	DurationSeconds float64   `json:"durationSeconds"`
+}
+
+// ClusterMetadata queries the Kubernetes version and node count of the cluster.
+func (f *Framework) ClusterMetadata() (ClusterMetadata, error) {
+	md := ClusterMetadata{
+		Provider: ClusterProvider,
+		CCMImage: Image,
+	}
+
+	info, err := f.kubeClient.Discovery().ServerVersion()
+	if err != nil {
+		return md, errors.Wrap(err, "getting server version")
+	}
+	md.KubernetesVersion = info.GitVersion
+
+	nodes, err := f.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return md, errors.Wrap(err, "listing nodes")
+	}
+	md.NodeCount = len(nodes.Items)
+
+	return md, nil
+}
+
+// WriteReports is meant to be called from ReportAfterSuite. It writes a JUnit
+// report and a SuiteSummary of report into ReportDir.
+func WriteReports(report ginkgo.Report, cluster ClusterMetadata) error {
+	if ReportDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(ReportDir, 0755); err != nil {
+		return err
+	}
+
+	if err := reporters.GenerateJUnitReport(report, filepath.Join(ReportDir, junitReportFile)); err != nil {
+		return errors.Wrap(err, "writing JUnit report")
+	}
+
+	data, err := json.MarshalIndent(summarize(report, cluster), "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(filepath.Join(ReportDir, jsonReportFile), data, 0644), "writing JSON report")
+}
+
+func summarize(report ginkgo.Report, cluster ClusterMetadata) SuiteSummary {
+	summary := SuiteSummary{
+		RunID:           RunID,
+		Suite:           report.SuiteDescription,
+		Passed:          report.SuiteSucceeded,
+		StartTime:       report.StartTime,
+		DurationSeconds: report.RunTime.Seconds(),
+		Cluster:         cluster,
+		Specs:           []SpecSummary{},
+	}
+
+	for _, spec := range report.SpecReports {
+		if spec.State == types.SpecStateSkipped || spec.State == types.SpecStatePending {
+			continue
+		}
+		s := SpecSummary{
+			Name:            spec.FullText(),
+			Type:            spec.LeafNodeType.String(),
+			State:           spec.State.String(),
+			DurationSeconds: spec.RunTime.Seconds(),
+			Steps:           stepSummaries(spec),
+			Retries:         retryCounts(spec),
+		}
+		if spec.Failed() {
+			s.Failure = spec.Failure.Message
+		}
+		summary.Specs = append(summary.Specs, s)
+	}
+	return summary
+}
+
+func stepSummaries(spec types.SpecReport) []StepSummary {
+	var steps []StepSummary
+	for _, entry := range spec.ReportEntries {
+		if entry.Name != byStepEntry {
+			continue
+		}
+		var value struct {
+			Text string
+		}
+		if err := decodeEntry(entry, &value); err != nil {
+			continue
+		}
+		if n := len(steps); n > 0 {
+			steps[n-1].DurationSeconds = entry.Time.Sub(steps[n-1].StartTime).Seconds()
+		}
+		steps = append(steps, StepSummary{Text: value.Text, StartTime: entry.Time})
+	}
+	if n := len(steps); n > 0 && !spec.EndTime.IsZero() {
+		steps[n-1].DurationSeconds = spec.EndTime.Sub(steps[n-1].StartTime).Seconds()
+	}
+	return steps
+}
+
+func retryCounts(spec types.SpecReport) []RetryCount {
+	var retries []RetryCount
+	for _, entry := range spec.ReportEntries {
+		if entry.Name != retriesEntry {
+			continue
+		}
+		var r RetryCount
+		if err := decodeEntry(entry, &r); err == nil {
+			retries = append(retries, r)
+		}
+	}
+	return retries
+}
+
+// decodeEntry decodes the value of a report entry. Entries reported by
+// parallel processes only carry the JSON, local ones only the raw value.
+func decodeEntry(entry types.ReportEntry, out interface{}) error {
+	data := []byte(entry.Value.AsJSON)
+	if len(data) == 0 {
+		var err error
+		if data, err = json.Marshal(entry.Value.GetRawValue()); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,125 @@
+package framework
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+)
+
+var _ = Describe("Reports", func() {
+	start := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	step := func(text string, at time.Duration) types.ReportEntry {
+		return types.ReportEntry{
+			Name:  byStepEntry,
+			Time:  start.Add(at),
+			Value: types.WrapEntryValue(struct{ Text string }{Text: text}),
+		}
+	}
+	report := types.Report{
+		SuiteDescription: "e2e Suite",
+		StartTime:        start,
+		RunTime:          time.Minute,
+		SpecReports: types.SpecReports{
+			{
+				LeafNodeType:  types.NodeTypeIt,
+				LeafNodeText:  "serves HTTP",
+				State:         types.SpecStatePassed,
+				StartTime:     start,
+				EndTime:       start.Add(30 * time.Second),
+				RunTime:       30 * time.Second,
+				ReportEntries: types.ReportEntries{step("Creating pods", 0), step("Waiting for the load balancer", 10*time.Second)},
+			},
+			{
+				LeafNodeType: types.NodeTypeIt,
+				LeafNodeText: "deletes the NodeBalancer",
+				State:        types.SpecStateFailed,
+				StartTime:    start,
+				EndTime:      start.Add(20 * time.Second),
+				RunTime:      20 * time.Second,
+				Failure:      types.Failure{Message: "timed out"},
+				ReportEntries: types.ReportEntries{{
+					Name:  retriesEntry,
+					Time:  start,
+					Value: types.WrapEntryValue(RetryCount{Operation: "loadbalancer-ingress", Attempts: 7, TimedOut: true}),
+				}},
+			},
+			{
+				LeafNodeType: types.NodeTypeIt,
+				LeafNodeText: "is skipped",
+				State:        types.SpecStateSkipped,
+			},
+		},
+	}
+
+	It("summarizes specs with step timings and retries", func() {
+		summary := summarize(report, ClusterMetadata{Provider: ProviderLKE, KubernetesVersion: "v1.26.1", NodeCount: 3})
+
+		Expect(summary.Suite).To(Equal("e2e Suite"))
+		Expect(summary.Cluster.NodeCount).To(Equal(3))
+		Expect(summary.Specs).To(HaveLen(2))
+
+		passed := summary.Specs[0]
+		Expect(passed.State).To(Equal("passed"))
+		Expect(passed.Steps).To(Equal([]StepSummary{
+			{Text: "Creating pods", StartTime: start, DurationSeconds: 10},
+			{Text: "Waiting for the load balancer", StartTime: start.Add(10 * time.Second), DurationSeconds: 20},
+		}))
+
+		failed := summary.Specs[1]
+		Expect(failed.Failure).To(Equal("timed out"))
+		Expect(failed.Retries).To(Equal([]RetryCount{{Operation: "loadbalancer-ingress", Attempts: 7, TimedOut: true}}))
+	})
+
+	It("writes JUnit and JSON reports", func() {
+		dir := GinkgoT().TempDir()
+		DeferCleanup(func(previous string) { ReportDir = previous }, ReportDir)
+		ReportDir = dir
+
+		Expect(WriteReports(report, ClusterMetadata{Provider: ProviderKind})).To(Succeed())
+
+		Expect(filepath.Join(dir, junitReportFile)).To(BeAnExistingFile())
+		data, err := ioutil.ReadFile(filepath.Join(dir, jsonReportFile))
+		Expect(err).NotTo(HaveOccurred())
+		var summary SuiteSummary
+		Expect(json.Unmarshal(data, &summary)).To(Succeed())
+		Expect(summary.Cluster.Provider).To(Equal(ProviderKind))
+		Expect(summary.Specs[0].Steps).To(HaveLen(2))
+	})
+
+	It("records the attempts of wait loops on the current spec", func() {
+		attempts := 0
+		Expect(poll("test-op", time.Millisecond, time.Second, func() (bool, error) {
+			attempts++
+			return attempts == 3, nil
+		})).To(Succeed())
+
+		retries := retryCounts(CurrentSpecReport())
+		Expect(retries).To(HaveLen(1))
+		Expect(retries[0].Operation).To(Equal("test-op"))
+		Expect(retries[0].Attempts).To(Equal(3))
+		Expect(retries[0].TimedOut).To(BeFalse())
+	})
+
+	It("collects cluster metadata", func() {
+		f := newFakeFramework(&core.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}, &core.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}})
+		f.kubeClient.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.26.1"}
+
+		md, err := f.ClusterMetadata()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(md).To(Equal(ClusterMetadata{
+			Provider:          ClusterProvider,
+			KubernetesVersion: "v1.26.1",
+			NodeCount:         2,
+			CCMImage:          Image,
+		}))
+	})
+})
@@ -0,0 +1,177 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/pkg/errors"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	// KeepOnFailure skips the deletion of spec objects, namespaces and the
+	// cluster once a spec failed, so the failure can be inspected.
+	KeepOnFailure = false
+	// RetainedManifest is where the resources kept by KeepOnFailure are listed.
+	RetainedManifest = "retained-resources.json"
+
+	retained = &Retained{}
+)
+
+// Retained lists what a run kept because of KeepOnFailure. Sweep it with
+// SweepRetained once the failure has been inspected.
+type Retained struct {
+	mu     sync.Mutex
+	failed bool
+
+	RunID      string            `json:"runID"`
+	Cluster    *RetainedCluster  `json:"cluster,omitempty"`
+	Namespaces []string          `json:"namespaces,omitempty"`
+	Resources  []TrackedResource `json:"resources,omitempty"`
+}
+
+type RetainedCluster struct {
+	Provider   string `json:"provider"`
+	Name       string `json:"name"`
+	Kubeconfig string `json:"kubeconfig"`
+}
+
+// MarkFailed records that the run failed outside of an Invocation, e.g. in
+// BeforeSuite or in a spec that does not use the framework.
+func MarkFailed() {
+	retained.mu.Lock()
+	defer retained.mu.Unlock()
+	retained.failed = true
+}
+
+// KeepResources reports whether resources should be kept instead of deleted.
+func KeepResources() bool {
+	retained.mu.Lock()
+	defer retained.mu.Unlock()
+	return KeepOnFailure && retained.failed
+}
+
+// keepFailedSpec is called from the cleanup of an Invocation. When the spec
+// failed and KeepOnFailure is set it records what is left behind and tells
+// the caller to skip the deletion.
+func keepFailedSpec(namespace string, resources []TrackedResource) bool {
+	if !KeepOnFailure || !ginkgo.CurrentSpecReport().Failed() {
+		return false
+	}
+
+	retained.mu.Lock()
+	defer retained.mu.Unlock()
+	retained.failed = true
+	if namespace != "" {
+		retained.Namespaces = append(retained.Namespaces, namespace)
+	}
+	retained.Resources = append(retained.Resources, resources...)
+
+	return true
+}
+
+// WriteRetained writes the list of kept resources to RetainedManifest and
+// prints how to inspect them.
+func WriteRetained(cluster RetainedCluster, namespace string) error {
+	retained.mu.Lock()
+	defer retained.mu.Unlock()
+
+	retained.RunID = RunID
+	retained.Cluster = &cluster
+	if namespace != "" && !containsString(retained.Namespaces, namespace) {
+		retained.Namespaces = append(retained.Namespaces, namespace)
+	}
+
+	data, err := json.MarshalIndent(retained, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(RetainedManifest, data, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nKeeping resources of failed run %s, listed in %s\n", RunID, RetainedManifest)
+	fmt.Printf("  kubeconfig: %s\n", cluster.Kubeconfig)
+	for _, ns := range retained.Namespaces {
+		fmt.Printf("  namespace:  %s\n", ns)
+		fmt.Printf("    kubectl --kubeconfig %s -n %s get all,events\n", cluster.Kubeconfig, ns)
+	}
+	fmt.Printf("Remove them with: go run ./cmd/sweep --retained %s\n\n", RetainedManifest)
+
+	return nil
+}
+
+// LoadRetained reads a manifest written by WriteRetained.
+func LoadRetained(path string) (*Retained, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Retained{}
+	return r, errors.Wrapf(json.Unmarshal(data, r), "parsing %s", path)
+}
+
+// SweepRetained deletes what a run kept: the cluster if it created one,
+// otherwise the retained namespaces of the existing cluster.
+func SweepRetained(ctx context.Context, r *Retained, sweeper *Sweeper) error {
+	if r.Cluster == nil {
+		return errors.New("retained manifest has no cluster")
+	}
+	switch r.Cluster.Provider {
+	case ProviderLKE:
+		return sweeper.DeleteCluster(ctx, r.Cluster.Name)
+	case ProviderTerraform, ProviderKind:
+		if sweeper.DryRun {
+			glog.Infof("Would delete %s cluster %s", r.Cluster.Provider, r.Cluster.Name)
+			return nil
+		}
+		return retainedProvisioner(r.Cluster).Delete(ctx)
+	case ProviderExisting:
+	default:
+		return errors.Errorf("cannot sweep clusters of provider %q", r.Cluster.Provider)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", r.Cluster.Kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ns := range r.Namespaces {
+		err := client.CoreV1().Namespaces().Delete(ctx, ns, metav1.DeleteOptions{})
+		if err != nil && !kerr.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// retainedProvisioner returns a provisioner of a terraform or kind cluster
+// created by an earlier run, so that it can be deleted.
+func retainedProvisioner(cluster *RetainedCluster) ClusterProvisioner {
+	if cluster.Provider == ProviderKind {
+		return &KindProvisioner{name: cluster.Name, kubeconfig: cluster.Kubeconfig}
+	}
+	return &TerraformProvisioner{name: cluster.Name, kubeconfig: cluster.Kubeconfig}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
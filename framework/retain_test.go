@@ -0,0 +1,65 @@
+package framework
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retained resources", func() {
+	BeforeEach(func() {
+		manifest, keep, runID := RetainedManifest, KeepOnFailure, RunID
+		DeferCleanup(func() {
+			RetainedManifest, KeepOnFailure, RunID = manifest, keep, runID
+			retained = &Retained{}
+		})
+		RetainedManifest = filepath.Join(GinkgoT().TempDir(), "retained.json")
+		RunID = "e2e-run-1234"
+	})
+
+	It("only keeps resources once the run failed with KeepOnFailure", func() {
+		Expect(KeepResources()).To(BeFalse())
+		MarkFailed()
+		Expect(KeepResources()).To(BeFalse())
+		KeepOnFailure = true
+		Expect(KeepResources()).To(BeTrue())
+	})
+
+	It("writes a manifest that sweeps the LKE cluster", func() {
+		api := newFakeLinodeAPI()
+		DeferCleanup(api.Close)
+		api.addCluster("ccm-linode1234", time.Now(), []string{E2ETag, "e2e-run-1234"})
+		sweeper := NewSweeper("fake-token")
+		sweeper.client = api.client()
+
+		retained.Namespaces = []string{"lke1234-spec"}
+		retained.Resources = []TrackedResource{{Kind: "Service", Namespace: "lke1234", Name: "hello"}}
+		Expect(WriteRetained(RetainedCluster{
+			Provider:   ProviderLKE,
+			Name:       "ccm-linode1234",
+			Kubeconfig: "/tmp/ccm-linode1234.conf",
+		}, "lke1234")).To(Succeed())
+
+		r, err := LoadRetained(RetainedManifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.RunID).To(Equal("e2e-run-1234"))
+		Expect(r.Namespaces).To(ConsistOf("lke1234-spec", "lke1234"))
+		Expect(r.Resources).To(ConsistOf(HaveField("Name", "hello")))
+
+		Expect(SweepRetained(context.Background(), r, sweeper)).To(Succeed())
+		Expect(api.clusters).To(BeEmpty())
+	})
+
+	It("refuses to sweep clusters of unknown providers", func() {
+		sweeper := NewSweeper("fake-token")
+		sweeper.DryRun = true
+		r := &Retained{Cluster: &RetainedCluster{Provider: ProviderKind, Name: "ccm-linode1234"}}
+		Expect(SweepRetained(context.Background(), r, sweeper)).To(Succeed())
+
+		r.Cluster.Provider = "minikube"
+		Expect(SweepRetained(context.Background(), r, sweeper)).To(MatchError(`cannot sweep clusters of provider "minikube"`))
+	})
+})
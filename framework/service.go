@@ -2,44 +2,58 @@ package framework
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
-	"time"
+	"strings"
 
 	"github.com/pkg/errors"
 	core "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-func (i *k8sInvocation) CreateService(serviceName string, selector, annotations map[string]string) error {
-	_, err := i.kubeClient.CoreV1().Services(i.Namespace()).Create(context.TODO(), &core.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        serviceName,
-			Namespace:   i.Namespace(),
-			Annotations: annotations,
-		},
-		Spec: core.ServiceSpec{
-			Ports: []core.ServicePort{
-				{
-					Port:       80,
-					TargetPort: intstr.FromInt(80),
-					Protocol:   "TCP",
-				},
-			},
-			Selector: selector,
-			Type:     core.ServiceTypeLoadBalancer,
-		},
-	}, metav1.CreateOptions{})
+const (
+	annLoadBalancerTags            = "service.beta.kubernetes.io/linode-loadbalancer-tags"
+	annLoadBalancerDefaultProtocol = "service.beta.kubernetes.io/linode-loadbalancer-default-protocol"
+	annLoadBalancerPortPrefix      = "service.beta.kubernetes.io/linode-loadbalancer-port-"
+)
 
+// loadBalancerPortConfig is the value of the per port annotation.
+type loadBalancerPortConfig struct {
+	Protocol      string `json:"protocol,omitempty"`
+	TLSSecretName string `json:"tls-secret-name,omitempty"`
+}
+
+// portScheme returns the scheme the NodeBalancer serves port with.
+func portScheme(svc *core.Service, port int32) string {
+	protocol := svc.Annotations[annLoadBalancerDefaultProtocol]
+	var config loadBalancerPortConfig
+	if err := json.Unmarshal([]byte(svc.Annotations[fmt.Sprintf("%s%d", annLoadBalancerPortPrefix, port)]), &config); err == nil && config.Protocol != "" {
+		protocol = config.Protocol
+	}
+	if strings.EqualFold(protocol, "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// CreateService creates a LoadBalancer Service forwarding TCP port 80. Use
+// NewService for any other shape.
+func (i *k8sInvocation) CreateService(serviceName string, selector, annotations map[string]string) error {
+	_, err := i.NewService(serviceName).
+		WithSelector(selector).
+		WithAnnotations(annotations).
+		Create()
 	return err
 }
 
+// GetHTTPEndpoints waits for the load balancer of the Service in the invocation
+// namespace and returns a URL for every IP and port.
 func (i *k8sInvocation) GetHTTPEndpoints(name string) ([]string, error) {
 	var serverAddr []string
 
-	svc, err := i.GetServiceWithLoadBalancerStatus(name, "default")
+	svc, err := i.GetServiceWithLoadBalancerStatus(name, i.Namespace())
 	if err != nil {
 		return serverAddr, err
 	}
@@ -60,7 +74,7 @@ func (i *k8sInvocation) GetHTTPEndpoints(name string) ([]string, error) {
 
 	for _, port := range ports {
 		for _, ip := range ips {
-			u, err := url.Parse(fmt.Sprintf("http://%s:%d", ip, port))
+			u, err := url.Parse(fmt.Sprintf("%s://%s:%d", portScheme(svc, port), ip, port))
 			if err != nil {
 				return nil, err
 			}
@@ -71,25 +85,37 @@ func (i *k8sInvocation) GetHTTPEndpoints(name string) ([]string, error) {
 	return serverAddr, nil
 }
 
+// GetServiceWithLoadBalancerStatus waits up to Timeouts.LoadBalancer for the
+// Service to get a load balancer ingress. On timeout the error describes the
+// last observed Service and its recent events.
 func (i *k8sInvocation) GetServiceWithLoadBalancerStatus(name, namespace string) (*core.Service, error) {
-	var (
-		svc *core.Service
-		err error
-	)
-	err = wait.PollImmediate(2*time.Second, 20*time.Minute, func() (bool, error) {
-		svc, err = i.kubeClient.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
-		if err != nil || len(svc.Status.LoadBalancer.Ingress) == 0 { // retry
-			return false, nil
-		} else {
-			return true, nil
-		}
+	svc, err := waitForService(context.TODO(), i.kubeClient, "loadbalancer-ingress", i.Timeouts.LoadBalancer, namespace, name, func(svc *core.Service) (bool, error) {
+		return svc != nil && len(svc.Status.LoadBalancer.Ingress) > 0, nil
 	})
 	if err != nil {
-		return nil, errors.Errorf("failed to get Status.LoadBalancer.Ingress for service %s/%s", name, namespace)
+		msg := fmt.Sprintf("service %s/%s got no load balancer ingress within %s", namespace, name, i.Timeouts.LoadBalancer)
+		if err != wait.ErrWaitTimeout {
+			msg += fmt.Sprintf(": %v", err)
+		}
+		if svc != nil {
+			msg += "\n" + describeServiceStatus(svc)
+		} else {
+			msg += ", the service does not exist"
+		}
+		return nil, errors.New(msg + "\n" + i.describeEvents(namespace, "Service", name))
 	}
 	return svc, nil
 }
 
+// describeServiceStatus summarizes what a pending LoadBalancer is waiting on.
+func describeServiceStatus(svc *core.Service) string {
+	s := sprintTable(func(w io.Writer) { printServices(w, []core.Service{*svc}) })
+	for _, c := range svc.Status.Conditions {
+		s += fmt.Sprintf("\ncondition %s=%s: %s %s", c.Type, c.Status, c.Reason, c.Message)
+	}
+	return s
+}
+
 func (i *k8sInvocation) DeleteService(name string) error {
 	err := i.kubeClient.CoreV1().Services(i.Namespace()).Delete(context.TODO(), name, *deleteInForeground())
 	return err
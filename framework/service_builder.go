@@ -0,0 +1,169 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ServiceBuilder builds a Service for a spec. Start with NewService, chain the
+// With* methods and finish with Create, or Object to tweak the Service further.
+//
+//	svc, err := f.Cluster.NewService("dns").
+//		WithSelector(labels).
+//		WithUDPPort(53, 5353).
+//		WithTCPPort(53, 5353).
+//		WithExternalTrafficPolicy(core.ServiceExternalTrafficPolicyTypeLocal).
+//		Create()
+type ServiceBuilder struct {
+	i   *k8sInvocation
+	svc *core.Service
+}
+
+// NewService starts a LoadBalancer Service in the namespace of the Invocation.
+// Its NodeBalancer is tagged with ResourceTags so a Sweeper can find it if the
+// run crashes. Without ports the Service forwards TCP port 80 to port 80.
+func (i *k8sInvocation) NewService(name string) *ServiceBuilder {
+	return &ServiceBuilder{
+		i: i,
+		svc: &core.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: i.Namespace(),
+				Annotations: map[string]string{
+					annLoadBalancerTags: strings.Join(ResourceTags(), ","),
+				},
+			},
+			Spec: core.ServiceSpec{
+				Type: core.ServiceTypeLoadBalancer,
+			},
+		},
+	}
+}
+
+func (b *ServiceBuilder) WithType(t core.ServiceType) *ServiceBuilder {
+	b.svc.Spec.Type = t
+	return b
+}
+
+func (b *ServiceBuilder) WithSelector(selector map[string]string) *ServiceBuilder {
+	b.svc.Spec.Selector = selector
+	return b
+}
+
+func (b *ServiceBuilder) WithLabels(labels map[string]string) *ServiceBuilder {
+	b.svc.Labels = labels
+	return b
+}
+
+// WithAnnotations adds annotations, replacing earlier values of the same keys.
+func (b *ServiceBuilder) WithAnnotations(annotations map[string]string) *ServiceBuilder {
+	for k, v := range annotations {
+		b.svc.Annotations[k] = v
+	}
+	return b
+}
+
+func (b *ServiceBuilder) WithAnnotation(key, value string) *ServiceBuilder {
+	b.svc.Annotations[key] = value
+	return b
+}
+
+// WithPort adds a port as is. The other port methods are shorthands for it.
+func (b *ServiceBuilder) WithPort(port core.ServicePort) *ServiceBuilder {
+	b.svc.Spec.Ports = append(b.svc.Spec.Ports, port)
+	return b
+}
+
+func (b *ServiceBuilder) WithTCPPort(port int32, targetPort int) *ServiceBuilder {
+	return b.WithPort(core.ServicePort{Protocol: core.ProtocolTCP, Port: port, TargetPort: intstr.FromInt(targetPort)})
+}
+
+func (b *ServiceBuilder) WithUDPPort(port int32, targetPort int) *ServiceBuilder {
+	return b.WithPort(core.ServicePort{Protocol: core.ProtocolUDP, Port: port, TargetPort: intstr.FromInt(targetPort)})
+}
+
+// WithNamedPort adds a TCP port called name that forwards to the container
+// port called targetPort.
+func (b *ServiceBuilder) WithNamedPort(name string, port int32, targetPort string) *ServiceBuilder {
+	return b.WithPort(core.ServicePort{Name: name, Protocol: core.ProtocolTCP, Port: port, TargetPort: intstr.FromString(targetPort)})
+}
+
+// WithHTTPS adds a TCP port the NodeBalancer terminates TLS on, with the
+// certificate of the kubernetes.io/tls Secret tlsSecretName, see
+// CreateTLSSecret. The backends are spoken to in plain HTTP.
+func (b *ServiceBuilder) WithHTTPS(port int32, targetPort int, tlsSecretName string) *ServiceBuilder {
+	config, _ := json.Marshal(loadBalancerPortConfig{Protocol: "https", TLSSecretName: tlsSecretName})
+	b.svc.Annotations[fmt.Sprintf("%s%d", annLoadBalancerPortPrefix, port)] = string(config)
+	return b.WithTCPPort(port, targetPort)
+}
+
+func (b *ServiceBuilder) WithExternalTrafficPolicy(policy core.ServiceExternalTrafficPolicyType) *ServiceBuilder {
+	b.svc.Spec.ExternalTrafficPolicy = policy
+	return b
+}
+
+func (b *ServiceBuilder) WithLoadBalancerSourceRanges(cidrs ...string) *ServiceBuilder {
+	b.svc.Spec.LoadBalancerSourceRanges = append(b.svc.Spec.LoadBalancerSourceRanges, cidrs...)
+	return b
+}
+
+// WithSessionAffinity sets the affinity. For ClientIP a timeoutSeconds of 0
+// keeps the Kubernetes default.
+func (b *ServiceBuilder) WithSessionAffinity(affinity core.ServiceAffinity, timeoutSeconds int32) *ServiceBuilder {
+	b.svc.Spec.SessionAffinity = affinity
+	b.svc.Spec.SessionAffinityConfig = nil
+	if affinity == core.ServiceAffinityClientIP && timeoutSeconds > 0 {
+		b.svc.Spec.SessionAffinityConfig = &core.SessionAffinityConfig{
+			ClientIP: &core.ClientIPConfig{TimeoutSeconds: &timeoutSeconds},
+		}
+	}
+	return b
+}
+
+// WithIPFamilies sets the IP families in order of preference. More than one
+// family asks for a dual-stack Service if the cluster supports it.
+func (b *ServiceBuilder) WithIPFamilies(families ...core.IPFamily) *ServiceBuilder {
+	b.svc.Spec.IPFamilies = families
+	policy := core.IPFamilyPolicySingleStack
+	if len(families) > 1 {
+		policy = core.IPFamilyPolicyPreferDualStack
+	}
+	b.svc.Spec.IPFamilyPolicy = &policy
+	return b
+}
+
+// Object returns the Service built so far. Unnamed ports get a name when
+// there is more than one, as the API requires.
+func (b *ServiceBuilder) Object() *core.Service {
+	svc := b.svc.DeepCopy()
+	if len(svc.Spec.Ports) == 0 {
+		svc.Spec.Ports = []core.ServicePort{{Protocol: core.ProtocolTCP, Port: 80, TargetPort: intstr.FromInt(80)}}
+	}
+	if len(svc.Spec.Ports) > 1 {
+		for idx := range svc.Spec.Ports {
+			port := &svc.Spec.Ports[idx]
+			if port.Name == "" {
+				port.Name = fmt.Sprintf("%s-%d", strings.ToLower(string(port.Protocol)), port.Port)
+			}
+		}
+	}
+	return svc
+}
+
+// Create creates the Service and deletes it when the spec ends.
+func (b *ServiceBuilder) Create() (*core.Service, error) {
+	svc, err := b.i.kubeClient.CoreV1().Services(b.svc.Namespace).Create(context.TODO(), b.Object(), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	name := svc.Name
+	b.i.tracker.track("Service", svc.Namespace, name, func() error { return b.i.DeleteService(name) })
+
+	return svc, nil
+}
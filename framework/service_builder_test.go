@@ -0,0 +1,80 @@
+package framework
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("ServiceBuilder", func() {
+	var inv *Invocation
+
+	BeforeEach(func() {
+		var err error
+		inv, err = newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("defaults to a tagged LoadBalancer on TCP port 80", func() {
+		svc, err := inv.Cluster.NewService("hello").WithSelector(map[string]string{"app": "hello"}).Create()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(svc.Namespace).To(Equal(inv.Namespace()))
+		Expect(svc.Spec.Type).To(Equal(core.ServiceTypeLoadBalancer))
+		Expect(svc.Spec.Ports).To(Equal([]core.ServicePort{{Protocol: core.ProtocolTCP, Port: 80, TargetPort: intstr.FromInt(80)}}))
+		Expect(svc.Annotations).To(HaveKey(annLoadBalancerTags))
+		Expect(inv.Resources()).To(ConsistOf(HaveField("Name", "hello")))
+	})
+
+	It("builds every service shape", func() {
+		svc, err := inv.Cluster.NewService("dns").
+			WithType(core.ServiceTypeNodePort).
+			WithAnnotation(annLoadBalancerTags, "custom").
+			WithUDPPort(53, 5353).
+			WithTCPPort(53, 5353).
+			WithNamedPort("http", 80, "web").
+			WithExternalTrafficPolicy(core.ServiceExternalTrafficPolicyTypeLocal).
+			WithLoadBalancerSourceRanges("10.0.0.0/8", "192.168.0.0/16").
+			WithSessionAffinity(core.ServiceAffinityClientIP, 600).
+			WithIPFamilies(core.IPv4Protocol, core.IPv6Protocol).
+			Create()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(svc.Spec.Type).To(Equal(core.ServiceTypeNodePort))
+		Expect(svc.Annotations).To(HaveKeyWithValue(annLoadBalancerTags, "custom"))
+		Expect(svc.Spec.Ports).To(Equal([]core.ServicePort{
+			{Name: "udp-53", Protocol: core.ProtocolUDP, Port: 53, TargetPort: intstr.FromInt(5353)},
+			{Name: "tcp-53", Protocol: core.ProtocolTCP, Port: 53, TargetPort: intstr.FromInt(5353)},
+			{Name: "http", Protocol: core.ProtocolTCP, Port: 80, TargetPort: intstr.FromString("web")},
+		}))
+		Expect(svc.Spec.ExternalTrafficPolicy).To(Equal(core.ServiceExternalTrafficPolicyTypeLocal))
+		Expect(svc.Spec.LoadBalancerSourceRanges).To(ConsistOf("10.0.0.0/8", "192.168.0.0/16"))
+		Expect(svc.Spec.SessionAffinity).To(Equal(core.ServiceAffinityClientIP))
+		Expect(*svc.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds).To(BeEquivalentTo(600))
+		Expect(svc.Spec.IPFamilies).To(Equal([]core.IPFamily{core.IPv4Protocol, core.IPv6Protocol}))
+		Expect(*svc.Spec.IPFamilyPolicy).To(Equal(core.IPFamilyPolicyPreferDualStack))
+	})
+
+	It("annotates HTTPS ports so endpoints use https", func() {
+		svc, err := inv.Cluster.NewService("tls").
+			WithTCPPort(80, 8080).
+			WithHTTPS(443, 8080, "tls-secret").
+			Create()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(svc.Annotations).To(HaveKeyWithValue(annLoadBalancerPortPrefix+"443", `{"protocol":"https","tls-secret-name":"tls-secret"}`))
+
+		Expect(portScheme(svc, 80)).To(Equal("http"))
+		Expect(portScheme(svc, 443)).To(Equal("https"))
+		svc.Annotations[annLoadBalancerDefaultProtocol] = "https"
+		Expect(portScheme(svc, 80)).To(Equal("https"))
+	})
+
+	It("keeps the builder usable after Object", func() {
+		b := inv.Cluster.NewService("hello")
+		obj := b.Object()
+		obj.Spec.Type = core.ServiceTypeClusterIP
+
+		Expect(b.Object().Spec.Type).To(Equal(core.ServiceTypeLoadBalancer))
+	})
+})
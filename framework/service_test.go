@@ -0,0 +1,103 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Namespaced getters", func() {
+	var inv *Invocation
+
+	BeforeEach(func() {
+		var err error
+		inv, err = newFakeFramework().Invoke(WithIsolatedNamespace())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	createLoadBalancer := func(namespace, ip string) {
+		svc := &core.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "wordpress", Namespace: namespace},
+			Spec: core.ServiceSpec{
+				Type:  core.ServiceTypeLoadBalancer,
+				Ports: []core.ServicePort{{Port: 80, NodePort: 30080}},
+			},
+			Status: core.ServiceStatus{LoadBalancer: core.LoadBalancerStatus{
+				Ingress: []core.LoadBalancerIngress{{IP: ip}},
+			}},
+		}
+		_, err := inv.kubeClient.CoreV1().Services(namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("reads Services from the invocation namespace", func() {
+		createLoadBalancer("default", "192.0.2.1")
+		createLoadBalancer(inv.Namespace(), "192.0.2.2")
+
+		urls, err := inv.Cluster.GetHTTPEndpoints("wordpress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(urls).To(Equal([]string{"http://192.0.2.2:80"}))
+
+		urls, err = inv.Cluster.InNamespace("default").GetHTTPEndpoints("wordpress")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(urls).To(Equal([]string{"http://192.0.2.1:80"}))
+	})
+
+	It("creates and tracks objects in the namespace of InNamespace", func() {
+		other := inv.Cluster.InNamespace("kube-system")
+		Expect(other.Namespace()).To(Equal("kube-system"))
+		Expect(inv.Cluster.Namespace()).NotTo(Equal("kube-system"))
+
+		_, err := other.NewService("dns").Create()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Resources()).To(ConsistOf(And(HaveField("Namespace", "kube-system"), HaveField("Name", "dns"))))
+	})
+
+	It("installs helm charts into the invocation namespace", func() {
+		Expect(inv.Cluster.helmArgs("install", "wordpress", "bitnami/wordpress")).To(Equal([]string{
+			"install", "wordpress", "bitnami/wordpress", "--kubeconfig", "", "--namespace", inv.Namespace(),
+		}))
+	})
+	It("describes the pending Service and its events on timeout", func() {
+		inv.Timeouts.LoadBalancer = 50 * time.Millisecond
+		_, err := inv.Cluster.NewService("pending").Create()
+		Expect(err).NotTo(HaveOccurred())
+		for i, msg := range []string{"Ensuring load balancer", "Error syncing load balancer: failed to ensure load balancer: quota exceeded"} {
+			_, err = inv.kubeClient.CoreV1().Events(inv.Namespace()).Create(context.TODO(), &core.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: fmt.Sprintf("pending.%d", i)},
+				InvolvedObject: core.ObjectReference{Kind: "Service", Name: "pending"},
+				Type:           core.EventTypeWarning,
+				Reason:         "SyncLoadBalancerFailed",
+				Message:        msg,
+				LastTimestamp:  metav1.NewTime(time.Now().Add(time.Duration(i) * time.Second)),
+			}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		_, err = inv.Cluster.GetHTTPEndpoints("pending")
+		Expect(err).To(MatchError(ContainSubstring("got no load balancer ingress within 50ms")))
+		Expect(err).To(MatchError(ContainSubstring("<pending>")))
+		Expect(err).To(MatchError(MatchRegexp(`(?s)Ensuring load balancer.*quota exceeded`)))
+
+		_, err = inv.Cluster.GetServiceWithLoadBalancerStatus("missing", inv.Namespace())
+		Expect(err).To(MatchError(ContainSubstring("the service does not exist")))
+		Expect(err).To(MatchError(ContainSubstring("no events for Service")))
+	})
+})
+
+var _ = Describe("Timeouts", func() {
+	It("falls back to --timeout for unset operations", func() {
+		t := Timeouts{LoadBalancer: time.Hour}.withDefault(time.Minute)
+		Expect(t).To(Equal(Timeouts{LoadBalancer: time.Hour, PodReady: time.Minute, DNS: time.Minute, Helm: time.Minute, Volume: time.Minute}))
+
+		inv, err := newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Timeouts.LoadBalancer).To(Equal(DefaultTimeouts().LoadBalancer))
+		Expect(inv.Timeouts.PodReady).To(Equal(Timeout))
+	})
+})
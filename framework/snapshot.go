@@ -0,0 +1,197 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var (
+	// VolumeSnapshotClass is the class of the VolumeSnapshots created by the
+	// snapshot specs. When empty they create a class for the CSI driver of
+	// StorageClass.
+	VolumeSnapshotClass = ""
+)
+
+const snapshotGroup = "snapshot.storage.k8s.io"
+
+var (
+	volumeSnapshotGVR      = schema.GroupVersionResource{Group: snapshotGroup, Version: "v1", Resource: "volumesnapshots"}
+	volumeSnapshotClassGVR = schema.GroupVersionResource{Group: snapshotGroup, Version: "v1", Resource: "volumesnapshotclasses"}
+)
+
+// SnapshotsSupported reports whether the cluster serves the VolumeSnapshot API
+// of the external snapshotter, which is not part of Kubernetes itself.
+func (i *k8sInvocation) SnapshotsSupported() (bool, error) {
+	groups, err := i.kubeClient.Discovery().ServerGroups()
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups.Groups {
+		if group.Name != snapshotGroup {
+			continue
+		}
+		for _, version := range group.Versions {
+			if version.Version == volumeSnapshotGVR.Version {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (i *k8sInvocation) GetVolumeSnapshotClassObject(name, driver string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": volumeSnapshotClassGVR.GroupVersion().String(),
+		"kind":       "VolumeSnapshotClass",
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+		"driver":         driver,
+		"deletionPolicy": "Delete",
+	}}
+}
+
+// CreateVolumeSnapshotClass creates a class for the CSI driver that
+// provisions StorageClass. It is deleted when the spec ends.
+func (i *k8sInvocation) CreateVolumeSnapshotClass(name string) (*unstructured.Unstructured, error) {
+	ctx := context.TODO()
+	sc, err := i.kubeClient.StorageV1().StorageClasses().Get(ctx, StorageClass, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting StorageClass %s", StorageClass)
+	}
+	class, err := i.dynamicClient.Resource(volumeSnapshotClassGVR).Create(ctx, i.GetVolumeSnapshotClassObject(name, sc.Provisioner), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	i.tracker.track("VolumeSnapshotClass", "", name, func() error {
+		return i.dynamicClient.Resource(volumeSnapshotClassGVR).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	})
+	return class, nil
+}
+
+// GetVolumeSnapshotObject returns a snapshot of the claim taken with
+// snapshotClass.
+func (i *k8sInvocation) GetVolumeSnapshotObject(name, claimName, snapshotClass string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": volumeSnapshotGVR.GroupVersion().String(),
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": i.Namespace(),
+		},
+		"spec": map[string]interface{}{
+			"volumeSnapshotClassName": snapshotClass,
+			"source": map[string]interface{}{
+				"persistentVolumeClaimName": claimName,
+			},
+		},
+	}}
+}
+
+// CreateVolumeSnapshot creates the snapshot and deletes it when the spec ends.
+func (i *k8sInvocation) CreateVolumeSnapshot(snapshot *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	snapshot, err := i.dynamicClient.Resource(volumeSnapshotGVR).Namespace(i.Namespace()).Create(context.TODO(), snapshot, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	name := snapshot.GetName()
+	i.tracker.track("VolumeSnapshot", i.Namespace(), name, func() error { return i.DeleteVolumeSnapshot(name) })
+
+	return snapshot, nil
+}
+
+func (i *k8sInvocation) DeleteVolumeSnapshot(name string) error {
+	return i.dynamicClient.Resource(volumeSnapshotGVR).Namespace(i.Namespace()).Delete(context.TODO(), name, *deleteInForeground())
+}
+
+// WaitForVolumeSnapshotReady waits up to Timeouts.Volume until the snapshot
+// is ready to restore from. It fails fast when the snapshotter reports an
+// error, which it also does for drivers without snapshot support.
+func (i *k8sInvocation) WaitForVolumeSnapshotReady(name string) (*unstructured.Unstructured, error) {
+	snapshot, err := waitForUnstructured(context.TODO(), i.dynamicClient, volumeSnapshotGVR, "volume-snapshot-ready", i.Timeouts.Volume, i.Namespace(), name, func(snapshot *unstructured.Unstructured) (bool, error) {
+		if snapshot == nil {
+			return false, nil
+		}
+		if msg, _, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); msg != "" {
+			return false, errors.Errorf("snapshot %s failed: %s", name, msg)
+		}
+		ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+		return ready, nil
+	})
+	if err != nil {
+		msg := fmt.Sprintf("volume snapshot %s/%s is not ready", i.Namespace(), name)
+		if err == wait.ErrWaitTimeout {
+			msg += fmt.Sprintf(" after %s", i.Timeouts.Volume)
+		} else {
+			msg += fmt.Sprintf(": %v", err)
+		}
+		if snapshot != nil {
+			msg += "\n" + describeSnapshotStatus(snapshot)
+		} else {
+			msg += ", the snapshot does not exist"
+		}
+		return nil, errors.New(msg + "\n" + i.describeEvents(i.Namespace(), "VolumeSnapshot", name))
+	}
+	return snapshot, nil
+}
+
+func describeSnapshotStatus(snapshot *unstructured.Unstructured) string {
+	status, found, _ := unstructured.NestedMap(snapshot.Object, "status")
+	if !found {
+		return "no status yet"
+	}
+	var fields []string
+	for _, key := range []string{"readyToUse", "boundVolumeSnapshotContentName", "restoreSize", "creationTime"} {
+		if v, ok := status[key]; ok {
+			fields = append(fields, fmt.Sprintf("%s %v", key, v))
+		}
+	}
+	return strings.Join(fields, ", ")
+}
+
+// GetRestoredClaimObject returns a claim of size that is restored from the
+// snapshot, size must be at least the size of the snapshotted claim.
+func (i *k8sInvocation) GetRestoredClaimObject(name, size, snapshotName string) *core.PersistentVolumeClaim {
+	pvc := i.GetPersistentVolumeClaimObject(name, size)
+	group := snapshotGroup
+	pvc.Spec.DataSource = &core.TypedLocalObjectReference{
+		APIGroup: &group,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshotName,
+	}
+	return pvc
+}
+
+// WriteRandomVolumeFile fills file below VolumeMountPath in the pod with
+// sizeMiB of random data and returns its SHA-256 checksum.
+func (i *k8sInvocation) WriteRandomVolumeFile(podName, file string, sizeMiB int) (string, error) {
+	path := VolumeMountPath + "/" + file
+	_, err := i.ExecInPod(podName, "sh", "-c", `dd if=/dev/urandom of="$1" bs=1M count="$2" 2>/dev/null && sync`,
+		"sh", path, fmt.Sprint(sizeMiB))
+	if err != nil {
+		return "", err
+	}
+	return i.VolumeFileChecksum(podName, file)
+}
+
+// VolumeFileChecksum returns the SHA-256 checksum of file below
+// VolumeMountPath in the pod.
+func (i *k8sInvocation) VolumeFileChecksum(podName, file string) (string, error) {
+	out, err := i.ExecInPod(podName, "sha256sum", VolumeMountPath+"/"+file)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", errors.Errorf("unexpected sha256sum output %q", out)
+	}
+	return fields[0], nil
+}
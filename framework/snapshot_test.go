@@ -0,0 +1,108 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	storage "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+)
+
+var _ = Describe("Volume snapshots", func() {
+	var (
+		inv *Invocation
+		ctx = context.TODO()
+	)
+
+	BeforeEach(func() {
+		var err error
+		inv, err = newFakeFramework(&storage.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: StorageClass},
+			Provisioner: "linodebs.csi.linode.com",
+		}).Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	setSnapshotStatus := func(name string, status map[string]interface{}) {
+		snapshots := inv.dynamicClient.Resource(volumeSnapshotGVR).Namespace(inv.Namespace())
+		snapshot, err := snapshots.Get(ctx, name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unstructured.SetNestedMap(snapshot.Object, status, "status")).To(Succeed())
+		_, err = snapshots.Update(ctx, snapshot, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("detects whether the cluster serves the snapshot API", func() {
+		Expect(inv.Cluster.SnapshotsSupported()).To(BeFalse())
+
+		discovery := inv.kubeClient.Discovery().(*fakediscovery.FakeDiscovery)
+		discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{GroupVersion: "snapshot.storage.k8s.io/v1"})
+		Expect(inv.Cluster.SnapshotsSupported()).To(BeTrue())
+	})
+
+	It("creates classes for the CSI driver of the StorageClass and deletes them with the spec", func() {
+		class, err := inv.Cluster.CreateVolumeSnapshotClass("e2e")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(class.Object).To(HaveKeyWithValue("driver", "linodebs.csi.linode.com"))
+		Expect(class.Object).To(HaveKeyWithValue("deletionPolicy", "Delete"))
+
+		_, err = inv.Cluster.CreateVolumeSnapshot(inv.Cluster.GetVolumeSnapshotObject("backup", "data", "e2e"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Resources()).To(HaveLen(2))
+
+		Expect(inv.Cleanup()).To(Succeed())
+		classes, err := inv.dynamicClient.Resource(volumeSnapshotClassGVR).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(classes.Items).To(BeEmpty())
+		snapshots, err := inv.dynamicClient.Resource(volumeSnapshotGVR).Namespace(inv.Namespace()).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots.Items).To(BeEmpty())
+	})
+
+	It("waits for snapshots to be ready to use", func() {
+		_, err := inv.Cluster.CreateVolumeSnapshot(inv.Cluster.GetVolumeSnapshotObject("backup", "data", "e2e"))
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(300 * time.Millisecond)
+			setSnapshotStatus("backup", map[string]interface{}{"readyToUse": true, "restoreSize": "10Gi"})
+		}()
+		snapshot, err := inv.Cluster.WaitForVolumeSnapshotReady("backup")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.Object).To(HaveKeyWithValue("status", HaveKeyWithValue("restoreSize", "10Gi")))
+	})
+
+	It("fails fast on snapshot errors and describes pending snapshots on timeout", func() {
+		_, err := inv.Cluster.CreateVolumeSnapshot(inv.Cluster.GetVolumeSnapshotObject("broken", "data", "e2e"))
+		Expect(err).NotTo(HaveOccurred())
+		setSnapshotStatus("broken", map[string]interface{}{
+			"readyToUse": false,
+			"error":      map[string]interface{}{"message": "driver does not support snapshots"},
+		})
+
+		start := time.Now()
+		_, err = inv.Cluster.WaitForVolumeSnapshotReady("broken")
+		Expect(err).To(MatchError(ContainSubstring("driver does not support snapshots")))
+		Expect(time.Since(start)).To(BeNumerically("<", inv.Timeouts.Volume))
+
+		inv.Timeouts.Volume = 200 * time.Millisecond
+		_, err = inv.Cluster.CreateVolumeSnapshot(inv.Cluster.GetVolumeSnapshotObject("pending", "data", "e2e"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inv.Cluster.WaitForVolumeSnapshotReady("pending")
+		Expect(err).To(MatchError(ContainSubstring("volume snapshot " + inv.Namespace() + "/pending is not ready after 200ms")))
+		Expect(err).To(MatchError(ContainSubstring("no status yet")))
+	})
+
+	It("builds claims restored from a snapshot", func() {
+		pvc := inv.Cluster.GetRestoredClaimObject("restored", "10Gi", "backup")
+		Expect(*pvc.Spec.StorageClassName).To(Equal(StorageClass))
+		Expect(*pvc.Spec.DataSource.APIGroup).To(Equal("snapshot.storage.k8s.io"))
+		Expect(pvc.Spec.DataSource.Kind).To(Equal("VolumeSnapshot"))
+		Expect(pvc.Spec.DataSource.Name).To(Equal("backup"))
+	})
+})
@@ -0,0 +1,200 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Sweeper deletes Linode resources left behind by crashed or interrupted runs.
+// A resource is swept when it carries Tag and is older than TTL.
+//
+// Block storage volumes are created by the CSI driver without tags, so when a
+// cluster is swept the volumes attached to its nodes are tagged first. They are
+// deleted by a later sweep, once the nodes are gone and they are detached.
+type Sweeper struct {
+	TTL    time.Duration
+	Tag    string
+	DryRun bool
+
+	client *linodeClient
+	now    func() time.Time
+}
+
+// SweptResource is a resource the Sweeper deleted, or would have deleted in
+// dry-run mode.
+type SweptResource struct {
+	Kind    string
+	ID      int
+	Label   string
+	Created time.Time
+}
+
+func NewSweeper(apiToken string) *Sweeper {
+	return &Sweeper{
+		TTL:    6 * time.Hour,
+		Tag:    E2ETag,
+		client: newLinodeClient(LinodeURL, apiToken),
+		now:    time.Now,
+	}
+}
+
+// Sweep deletes stale LKE clusters, Linodes of terraform clusters,
+// NodeBalancers and volumes. It keeps going after individual failures and
+// returns them aggregated.
+func (s *Sweeper) Sweep(ctx context.Context) ([]SweptResource, error) {
+	var (
+		swept []SweptResource
+		errs  []error
+	)
+
+	clusters, err := s.client.ListLKEClusters(ctx)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "listing LKE clusters"))
+	}
+	for _, c := range clusters {
+		created, stale := s.isStale(c.Tags, c.Created)
+		if !stale {
+			continue
+		}
+		res := SweptResource{Kind: "lke-cluster", ID: c.ID, Label: c.Label, Created: created}
+		if err := s.sweepCluster(ctx, c); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		swept = append(swept, res)
+	}
+
+	instances, err := s.client.ListInstances(ctx)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "listing Linodes"))
+	}
+	for _, l := range instances {
+		created, stale := s.isStale(l.Tags, l.Created)
+		if !stale {
+			continue
+		}
+		res := SweptResource{Kind: "linode", ID: l.ID, Label: l.Label, Created: created}
+		if err := s.delete(ctx, res, s.client.DeleteInstance); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		swept = append(swept, res)
+	}
+
+	nodeBalancers, err := s.client.ListNodeBalancers(ctx)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "listing NodeBalancers"))
+	}
+	for _, nb := range nodeBalancers {
+		created, stale := s.isStale(nb.Tags, nb.Created)
+		if !stale {
+			continue
+		}
+		res := SweptResource{Kind: "nodebalancer", ID: nb.ID, Label: nb.Label, Created: created}
+		if err := s.delete(ctx, res, s.client.DeleteNodeBalancer); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		swept = append(swept, res)
+	}
+
+	volumes, err := s.client.ListVolumes(ctx)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "listing volumes"))
+	}
+	for _, v := range volumes {
+		created, stale := s.isStale(v.Tags, v.Created)
+		if !stale {
+			continue
+		}
+		if v.LinodeID != nil {
+			glog.Infof("Skipping volume %s (%d), still attached to linode %d", v.Label, v.ID, *v.LinodeID)
+			continue
+		}
+		res := SweptResource{Kind: "volume", ID: v.ID, Label: v.Label, Created: created}
+		if err := s.delete(ctx, res, s.client.DeleteVolume); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		swept = append(swept, res)
+	}
+
+	return swept, utilerrors.NewAggregate(errs)
+}
+
+// DeleteCluster sweeps the LKE cluster with the given label regardless of its
+// age. Like Sweep, it only touches clusters carrying Tag.
+func (s *Sweeper) DeleteCluster(ctx context.Context, label string) error {
+	clusters, err := s.client.ListLKEClusters(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing LKE clusters")
+	}
+	for _, c := range clusters {
+		if c.Label == label && containsString(c.Tags, s.Tag) {
+			return s.sweepCluster(ctx, c)
+		}
+	}
+	glog.Infof("LKE cluster %s not found, nothing to sweep", label)
+	return nil
+}
+
+func (s *Sweeper) isStale(tags []string, created string) (time.Time, bool) {
+	if !containsString(tags, s.Tag) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(linodeTimeLayout, created)
+	if err != nil {
+		glog.Warningf("Ignoring resource with unparsable creation time %q: %v", created, err)
+		return time.Time{}, false
+	}
+	return t, s.now().UTC().Sub(t) > s.TTL
+}
+
+func (s *Sweeper) sweepCluster(ctx context.Context, c lkeCluster) error {
+	pools, err := s.client.ListLKENodePools(ctx, c.ID)
+	if err != nil && !isAPIStatus(err, 404) {
+		return errors.Wrapf(err, "listing node pools of LKE cluster %s", c.Label)
+	}
+	instances := map[int]bool{}
+	for _, pool := range pools {
+		for _, node := range pool.Nodes {
+			instances[node.InstanceID] = true
+		}
+	}
+
+	if len(instances) > 0 {
+		volumes, err := s.client.ListVolumes(ctx)
+		if err != nil {
+			return errors.Wrap(err, "listing volumes")
+		}
+		for _, v := range volumes {
+			if v.LinodeID == nil || !instances[*v.LinodeID] || containsString(v.Tags, s.Tag) {
+				continue
+			}
+			glog.Infof("Tagging volume %s (%d) of LKE cluster %s for a later sweep", v.Label, v.ID, c.Label)
+			if s.DryRun {
+				continue
+			}
+			if err := s.client.UpdateVolumeTags(ctx, v.ID, append(v.Tags, s.Tag)); err != nil {
+				return errors.Wrapf(err, "tagging volume %s", v.Label)
+			}
+		}
+	}
+
+	return s.delete(ctx, SweptResource{Kind: "lke-cluster", ID: c.ID, Label: c.Label}, s.client.DeleteLKECluster)
+}
+
+func (s *Sweeper) delete(ctx context.Context, res SweptResource, del func(context.Context, int) error) error {
+	glog.Infof("Sweeping %s %s (%d)", res.Kind, res.Label, res.ID)
+	if s.DryRun {
+		return nil
+	}
+	if err := del(ctx, res.ID); err != nil && !isAPIStatus(err, 404) {
+		return errors.Wrapf(err, "deleting %s %s", res.Kind, res.Label)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sweeper", func() {
+	var (
+		api     *fakeLinodeAPI
+		sweeper *Sweeper
+		now     = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+		old     = now.Add(-7 * time.Hour)
+		recent  = now.Add(-time.Hour)
+		tagged  = []string{E2ETag, "e2e-run-1234"}
+	)
+
+	intPtr := func(i int) *int { return &i }
+
+	BeforeEach(func() {
+		api = newFakeLinodeAPI()
+		DeferCleanup(api.Close)
+
+		sweeper = NewSweeper("fake-token")
+		sweeper.client = api.client()
+		sweeper.now = func() time.Time { return now }
+	})
+
+	It("deletes only tagged resources older than the TTL", func() {
+		staleCluster := api.addCluster("stale", old, tagged)
+		freshCluster := api.addCluster("fresh", recent, tagged)
+		foreignCluster := api.addCluster("production", old, []string{"prod"})
+		staleNB := api.addNodeBalancer(nodeBalancer{Label: "stale-nb", Tags: tagged}, old)
+		foreignNB := api.addNodeBalancer(nodeBalancer{Label: "prod-nb"}, old)
+		staleVolume := api.addVolume(volume{Label: "pvc-stale", Tags: tagged}, old)
+		attachedVolume := api.addVolume(volume{Label: "pvc-attached", Tags: tagged, LinodeID: intPtr(42)}, old)
+		staleLinode := api.addInstance(instance{Label: "stale-node-1", Tags: tagged}, old)
+		foreignLinode := api.addInstance(instance{Label: "web-1"}, old)
+
+		swept, err := sweeper.Sweep(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(swept).To(ConsistOf(
+			SweptResource{Kind: "lke-cluster", ID: staleCluster, Label: "stale", Created: old},
+			SweptResource{Kind: "linode", ID: staleLinode, Label: "stale-node-1", Created: old},
+			SweptResource{Kind: "nodebalancer", ID: staleNB, Label: "stale-nb", Created: old},
+			SweptResource{Kind: "volume", ID: staleVolume, Label: "pvc-stale", Created: old},
+		))
+		Expect(api.clusters).To(HaveLen(2))
+		Expect(api.clusters).To(HaveKey(freshCluster))
+		Expect(api.clusters).To(HaveKey(foreignCluster))
+		Expect(api.nodeBalancers).To(ConsistOf(HaveField("ID", foreignNB)))
+		Expect(api.volumes).To(ConsistOf(HaveField("ID", attachedVolume)))
+		Expect(api.instances).To(ConsistOf(HaveField("ID", foreignLinode)))
+	})
+
+	It("tags the volumes of swept clusters for the next sweep", func() {
+		api.addCluster("stale", old, tagged, 100, 101)
+		volumeID := api.addVolume(volume{Label: "pvc-untagged", LinodeID: intPtr(101)}, old)
+		unrelated := api.addVolume(volume{Label: "pvc-other", LinodeID: intPtr(7)}, old)
+
+		_, err := sweeper.Sweep(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(api.clusters).To(BeEmpty())
+		Expect(api.volumes[volumeID].Tags).To(ConsistOf(E2ETag))
+		Expect(api.volumes[unrelated].Tags).To(BeEmpty())
+
+		// The volume detaches once the nodes are gone.
+		api.volumes[volumeID].LinodeID = nil
+		swept, err := sweeper.Sweep(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(swept).To(ConsistOf(HaveField("ID", volumeID)))
+	})
+
+	It("deletes nothing in dry-run mode", func() {
+		api.addCluster("stale", old, tagged, 100)
+		api.addVolume(volume{Label: "pvc-untagged", LinodeID: intPtr(100)}, old)
+		api.addNodeBalancer(nodeBalancer{Label: "stale-nb", Tags: tagged}, old)
+		sweeper.DryRun = true
+
+		swept, err := sweeper.Sweep(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(swept).To(HaveLen(2))
+		Expect(api.clusters).To(HaveLen(1))
+		Expect(api.nodeBalancers).To(HaveLen(1))
+		for _, v := range api.volumes {
+			Expect(v.Tags).To(BeEmpty())
+		}
+	})
+})
+
+var _ = Describe("Teardown", func() {
+	It("runs steps once in reverse order and ignores resources that were never created", func() {
+		var order []string
+		t := NewTeardown()
+		t.Add("cluster", func(context.Context) error {
+			order = append(order, "cluster")
+			return ErrClusterNotCreated
+		})
+		t.Add("namespace", func(context.Context) error {
+			order = append(order, "namespace")
+			return nil
+		})
+
+		Expect(t.Run(context.Background())).To(Succeed())
+		Expect(t.Run(context.Background())).To(Succeed())
+		Expect(order).To(Equal([]string{"namespace", "cluster"}))
+	})
+})
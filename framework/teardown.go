@@ -0,0 +1,59 @@
+package framework
+
+import (
+	"context"
+	"sync"
+
+	"github.com/golang/glog"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Teardown collects cleanup steps for resources that outlive a single spec,
+// such as the cluster, and runs them exactly once from AfterSuite. Ginkgo runs
+// AfterSuite on SIGINT and SIGTERM too, after interrupting the current spec,
+// so the suite needs no signal handling of its own.
+type Teardown struct {
+	mu    sync.Mutex
+	steps []teardownStep
+	once  sync.Once
+	err   error
+}
+
+type teardownStep struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func NewTeardown() *Teardown {
+	return &Teardown{}
+}
+
+// Add registers fn to run on teardown. Steps run in reverse registration order.
+// A step returning ErrClusterNotCreated is considered successful, so steps can
+// be registered before the resource they delete is known to exist.
+func (t *Teardown) Add(name string, fn func(ctx context.Context) error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps = append(t.steps, teardownStep{name: name, fn: fn})
+}
+
+// Run executes the registered steps once. Concurrent and later calls wait for
+// the first one and return its result.
+func (t *Teardown) Run(ctx context.Context) error {
+	t.once.Do(func() {
+		t.mu.Lock()
+		steps := t.steps
+		t.mu.Unlock()
+
+		var errs []error
+		for i := len(steps) - 1; i >= 0; i-- {
+			glog.Infof("Teardown: %s", steps[i].name)
+			if err := steps[i].fn(ctx); err != nil && err != ErrClusterNotCreated {
+				glog.Errorf("Teardown of %s failed: %v", steps[i].name, err)
+				errs = append(errs, err)
+			}
+		}
+		t.err = utilerrors.NewAggregate(errs)
+	})
+	return t.err
+}
@@ -0,0 +1,87 @@
+package framework
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// TerraformProvisioner creates clusters with the terraform-linode-k8s module
+// through scripts/create_cluster.sh and scripts/delete_cluster.sh.
+type TerraformProvisioner struct {
+	apiToken   string
+	name       string
+	kubeconfig string
+}
+
+var _ ClusterProvisioner = &TerraformProvisioner{}
+
+func NewTerraformProvisioner(apiToken string) *TerraformProvisioner {
+	return &TerraformProvisioner{apiToken: apiToken}
+}
+
+func (p *TerraformProvisioner) Create(ctx context.Context, spec ClusterSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	if len(spec.NodePools) != 1 || spec.NodePools[0].Autoscaler != nil || spec.HighAvailability {
+		return errors.New("terraform provider only supports a single node pool without autoscaler or HA control plane")
+	}
+	if spec.KubernetesVersion != "" {
+		glog.Warningf("terraform provider ignores kubernetes version %s", spec.KubernetesVersion)
+	}
+
+	tags := append([]string(nil), spec.Tags...)
+	for _, tag := range ResourceTags() {
+		if !containsString(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	// Set before the apply, so Delete destroys what a failed apply created.
+	p.name = spec.Name
+	pool := spec.NodePools[0]
+	if err := RunScript("create_cluster.sh", p.apiToken, spec.Name, spec.Region, pool.Type, strconv.Itoa(pool.Count), strings.Join(tags, ",")); err != nil {
+		return err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	p.kubeconfig = filepath.Join(wd, spec.Name+".conf")
+
+	return nil
+}
+
+func (p *TerraformProvisioner) Kubeconfig(ctx context.Context) (string, error) {
+	if p.kubeconfig == "" {
+		return "", ErrClusterNotCreated
+	}
+	return p.kubeconfig, nil
+}
+
+func (p *TerraformProvisioner) Delete(ctx context.Context) error {
+	if p.name == "" {
+		return ErrClusterNotCreated
+	}
+	if err := RunScript("delete_cluster.sh"); err != nil {
+		return err
+	}
+	p.name = ""
+	p.kubeconfig = ""
+
+	return nil
+}
+
+func (p *TerraformProvisioner) Describe() string {
+	if p.name != "" {
+		return "terraform cluster " + p.name
+	}
+	return "terraform (scripts/create_cluster.sh)"
+}
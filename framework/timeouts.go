@@ -0,0 +1,46 @@
+package framework
+
+import (
+	"time"
+)
+
+// Timeouts bounds the wait loops of operations that usually take much longer
+// than Timeout. Zero durations fall back to Timeout.
+type Timeouts struct {
+	// LoadBalancer is how long a LoadBalancer Service may take to get an
+	// ingress IP, i.e. to provision a NodeBalancer.
+	LoadBalancer time.Duration `json:"loadBalancer,omitempty"`
+	// PodReady is how long a created pod may take to become ready.
+	PodReady time.Duration `json:"podReady,omitempty"`
+	// DNS is how long external-dns records may take to propagate.
+	DNS time.Duration `json:"dns,omitempty"`
+	// Helm is the timeout of a helm install, including the wait for its
+	// resources to become ready.
+	Helm time.Duration `json:"helm,omitempty"`
+	// Volume is how long a block storage volume may take to be provisioned,
+	// attached, detached, expanded or deleted.
+	Volume time.Duration `json:"volume,omitempty"`
+}
+
+// OperationTimeouts are the Timeouts of every Invocation, set by flags.
+var OperationTimeouts = DefaultTimeouts()
+
+// DefaultTimeouts returns the timeouts used unless flags override them.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		LoadBalancer: 20 * time.Minute,
+		DNS:          2 * time.Hour,
+		Helm:         10 * time.Minute,
+		Volume:       10 * time.Minute,
+	}
+}
+
+// withDefault replaces the unset timeouts with timeout.
+func (t Timeouts) withDefault(timeout time.Duration) Timeouts {
+	for _, d := range []*time.Duration{&t.LoadBalancer, &t.PodReady, &t.DNS, &t.Helm, &t.Volume} {
+		if *d == 0 {
+			*d = timeout
+		}
+	}
+	return t
+}
@@ -0,0 +1,224 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CertificateAuthority is a throwaway CA for TLS specs. Clients built from
+// it trust nothing but the certificates it issued.
+type CertificateAuthority struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+
+	key *rsa.PrivateKey
+}
+
+// ServingCert is a certificate issued by a CertificateAuthority, PEM encoded.
+type ServingCert struct {
+	Hosts   []string
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// TLSResponse is the answer to a TLS probe.
+type TLSResponse struct {
+	StatusCode int
+	Body       string
+	// Chain is the verified chain, from the served certificate to the CA.
+	Chain []*x509.Certificate
+}
+
+// NewCertificateAuthority creates a CA valid for a day.
+func NewCertificateAuthority() (*CertificateAuthority, error) {
+	key, template, err := certTemplate()
+	if err != nil {
+		return nil, err
+	}
+	template.Subject.CommonName = E2ETag + " CA"
+	template.IsCA = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CertificateAuthority{Cert: cert, CertPEM: encodePEM("CERTIFICATE", der), key: key}, nil
+}
+
+// Issue creates a serving certificate for hosts, which may be DNS names or
+// IPs. The first host is also the common name. Keys are RSA, NodeBalancers
+// do not accept other key types.
+func (ca *CertificateAuthority) Issue(hosts ...string) (*ServingCert, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("a serving certificate needs at least one host")
+	}
+	key, template, err := certTemplate()
+	if err != nil {
+		return nil, err
+	}
+	template.Subject.CommonName = hosts[0]
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return &ServingCert{
+		Hosts:   hosts,
+		CertPEM: encodePEM("CERTIFICATE", der),
+		KeyPEM:  encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+	}, nil
+}
+
+// TLSCertificate returns the certificate for a tls.Config, e.g. of a server.
+func (c *ServingCert) TLSCertificate() (tls.Certificate, error) {
+	return tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+}
+
+// Pool returns a pool holding only the CA.
+func (ca *CertificateAuthority) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// Client returns an HTTP client that trusts only the CA and sends serverName
+// as SNI, verifying the served certificate against it rather than the host of
+// the URL. That allows probing a NodeBalancer by IP for a name without DNS.
+func (ca *CertificateAuthority) Client(serverName string) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:    ca.Pool(),
+				ServerName: serverName,
+				MinVersion: tls.VersionTLS12,
+			},
+		},
+	}
+}
+
+// GetHTTPSResponse requests url with Client(serverName). It fails unless the
+// served chain verifies against the CA for serverName.
+func (ca *CertificateAuthority) GetHTTPSResponse(url, serverName string) (*TLSResponse, error) {
+	resp, err := ca.Client(serverName).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.TLS == nil || len(resp.TLS.VerifiedChains) == 0 {
+		return nil, errors.Errorf("%s was not served over verified TLS", url)
+	}
+	chain := resp.TLS.VerifiedChains[0]
+	if !chain[len(chain)-1].Equal(ca.Cert) {
+		return nil, errors.Errorf("chain of %s does not end in the CA", url)
+	}
+	return &TLSResponse{StatusCode: resp.StatusCode, Body: string(body), Chain: chain}, nil
+}
+
+// WaitForHTTPSResponse waits until url answers 200 over TLS verified by ca
+// for serverName.
+func (i *Invocation) WaitForHTTPSResponse(url, serverName string, ca *CertificateAuthority) (*TLSResponse, error) {
+	var (
+		resp    *TLSResponse
+		lastErr error
+	)
+	err := poll("https-response", i.RetryInterval, i.Timeout, func() (bool, error) {
+		resp, lastErr = ca.GetHTTPSResponse(url, serverName)
+		if lastErr != nil {
+			return false, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = errors.Errorf("%s answered %d", url, resp.StatusCode)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(lastErr, "waiting for %s", url)
+	}
+	return resp, nil
+}
+
+// CreateTLSSecret creates a kubernetes.io/tls Secret holding cert. It is
+// deleted when the spec ends.
+func (i *k8sInvocation) CreateTLSSecret(name string, cert *ServingCert) (*core.Secret, error) {
+	secret, err := i.kubeClient.CoreV1().Secrets(i.Namespace()).Create(context.TODO(), &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: i.Namespace(),
+		},
+		Type: core.SecretTypeTLS,
+		Data: map[string][]byte{
+			core.TLSCertKey:       cert.CertPEM,
+			core.TLSPrivateKeyKey: cert.KeyPEM,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	i.tracker.track("Secret", i.Namespace(), name, func() error { return i.DeleteSecret(name) })
+
+	return secret, nil
+}
+
+func (i *k8sInvocation) DeleteSecret(name string) error {
+	return i.kubeClient.CoreV1().Secrets(i.Namespace()).Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
+
+func certTemplate() (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{E2ETag}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+	}, nil
+}
+
+func encodePEM(blockType string, der []byte) []byte {
+	var buf bytes.Buffer
+	_ = pem.Encode(&buf, &pem.Block{Type: blockType, Bytes: der})
+	return buf.Bytes()
+}
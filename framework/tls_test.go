@@ -0,0 +1,93 @@
+package framework
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+)
+
+var _ = Describe("TLS", func() {
+	var (
+		ca     *CertificateAuthority
+		server *httptest.Server
+	)
+
+	BeforeEach(func() {
+		var err error
+		ca, err = NewCertificateAuthority()
+		Expect(err).NotTo(HaveOccurred())
+
+		var certs []tls.Certificate
+		for _, host := range []string{"a.example.com", "b.example.com"} {
+			serving, err := ca.Issue(host)
+			Expect(err).NotTo(HaveOccurred())
+			cert, err := serving.TLSCertificate()
+			Expect(err).NotTo(HaveOccurred())
+			certs = append(certs, cert)
+		}
+
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "hello %s", r.TLS.ServerName)
+		}))
+		server.TLS = &tls.Config{Certificates: certs}
+		server.StartTLS()
+		DeferCleanup(server.Close)
+	})
+
+	It("verifies the chain and picks the certificate by SNI", func() {
+		for _, host := range []string{"a.example.com", "b.example.com"} {
+			resp, err := ca.GetHTTPSResponse(server.URL, host)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Body).To(Equal("hello " + host))
+			Expect(resp.Chain).To(HaveLen(2))
+			Expect(resp.Chain[0].DNSNames).To(Equal([]string{host}))
+			Expect(resp.Chain[1].Equal(ca.Cert)).To(BeTrue())
+		}
+	})
+
+	It("rejects certificates for other names", func() {
+		_, err := ca.GetHTTPSResponse(server.URL, "c.example.com")
+		Expect(err).To(MatchError(ContainSubstring("c.example.com")))
+	})
+
+	It("rejects certificates of other CAs", func() {
+		other, err := NewCertificateAuthority()
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = other.GetHTTPSResponse(server.URL, "a.example.com")
+		Expect(err).To(MatchError(ContainSubstring("unknown authority")))
+	})
+
+	It("issues certificates for IPs", func() {
+		serving, err := ca.Issue("127.0.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		cert, err := serving.TLSCertificate()
+		Expect(err).NotTo(HaveOccurred())
+		server.TLS.Certificates = []tls.Certificate{cert}
+
+		inv, err := newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := inv.WaitForHTTPSResponse(server.URL, "127.0.0.1", ca)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Chain[0].IPAddresses).To(HaveLen(1))
+	})
+
+	It("creates TLS Secrets", func() {
+		serving, err := ca.Issue("e2e.example.com")
+		Expect(err).NotTo(HaveOccurred())
+		inv, err := newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+
+		secret, err := inv.Cluster.CreateTLSSecret("tls", serving)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.Type).To(Equal(core.SecretTypeTLS))
+		Expect(secret.Data).To(HaveKeyWithValue(core.TLSCertKey, serving.CertPEM))
+		Expect(inv.Resources()).To(ConsistOf(HaveField("Name", "tls")))
+	})
+})
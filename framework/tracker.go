@@ -0,0 +1,83 @@
+package framework
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+var (
+	// SkipCleanup leaves everything a spec created in place, for debugging.
+	SkipCleanup = false
+)
+
+// TrackedResource is an object created through an Invocation.
+type TrackedResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+
+	delete func() error
+}
+
+func (r TrackedResource) String() string {
+	if r.Namespace == "" {
+		return r.Kind + " " + r.Name
+	}
+	return r.Kind + " " + r.Namespace + "/" + r.Name
+}
+
+// tracker records created objects so they can be deleted in reverse creation
+// order when the spec ends.
+type tracker struct {
+	mu        sync.Mutex
+	resources []TrackedResource
+}
+
+func (t *tracker) track(kind, namespace, name string, delete func() error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resources = append(t.resources, TrackedResource{Kind: kind, Namespace: namespace, Name: name, delete: delete})
+}
+
+func (t *tracker) list() []TrackedResource {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TrackedResource(nil), t.resources...)
+}
+
+// cleanup deletes the tracked resources newest first. Resources that are
+// already gone are not an error.
+func (t *tracker) cleanup() error {
+	t.mu.Lock()
+	resources := t.resources
+	t.resources = nil
+	t.mu.Unlock()
+
+	var errs []error
+	for i := len(resources) - 1; i >= 0; i-- {
+		r := resources[i]
+		glog.Infof("Cleaning up %s", r)
+		if err := r.delete(); err != nil && !isNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func isNotFound(err error) bool {
+	return kerr.IsNotFound(err)
+}
+
+// Resources returns the objects created through the invocation so far.
+func (r *rootInvocation) Resources() []TrackedResource {
+	return r.tracker.list()
+}
+
+// Cleanup deletes every object created through the invocation, newest first.
+// Invoke registers it with DeferCleanup unless SkipCleanup is set.
+func (r *rootInvocation) Cleanup() error {
+	return r.tracker.cleanup()
+}
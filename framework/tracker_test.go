@@ -0,0 +1,75 @@
+package framework
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Resource tracking", func() {
+	var (
+		f      *Framework
+		inv    *Invocation
+		client *fake.Clientset
+	)
+
+	BeforeEach(func() {
+		f = newFakeFramework()
+		client = f.kubeClient.(*fake.Clientset)
+
+		var err error
+		inv, err = f.Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	deletions := func() []string {
+		var deleted []string
+		for _, action := range client.Actions() {
+			if del, ok := action.(k8stesting.DeleteAction); ok {
+				deleted = append(deleted, del.GetResource().Resource+"/"+del.GetName())
+			}
+		}
+		return deleted
+	}
+
+	It("deletes created objects in reverse order and tolerates missing ones", func() {
+		Expect(inv.Cluster.CreateService("backend", nil, nil)).To(Succeed())
+		Expect(inv.Cluster.CreateNetworkPolicy(inv.Cluster.GetNetworkPolicyObject("deny", nil))).To(Succeed())
+		Expect(inv.Cluster.CreateService("frontend", nil, nil)).To(Succeed())
+		Expect(inv.Resources()).To(HaveLen(3))
+
+		Expect(inv.Cluster.DeleteService("backend")).To(Succeed())
+		client.ClearActions()
+
+		Expect(inv.Cleanup()).To(Succeed())
+
+		Expect(deletions()).To(Equal([]string{
+			"services/frontend",
+			"networkpolicies/deny",
+			"services/backend",
+		}))
+		Expect(inv.Resources()).To(BeEmpty())
+	})
+
+	It("does not track objects that failed to be created", func() {
+		client.PrependReactor("create", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("admission denied")
+		})
+
+		Expect(inv.Cluster.CreateService("backend", nil, nil)).NotTo(Succeed())
+		Expect(inv.Resources()).To(BeEmpty())
+	})
+
+	It("reports failures that merely mention not found", func() {
+		Expect(inv.Cluster.CreateService("backend", nil, nil)).To(Succeed())
+		client.PrependReactor("delete", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New(`exec: "helm": executable file not found in $PATH`)
+		})
+
+		Expect(inv.Cleanup()).To(MatchError(ContainSubstring("executable file not found")))
+	})
+})
@@ -2,8 +2,6 @@ package framework
 
 import (
 	"context"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -18,7 +16,6 @@ import (
 	"github.com/golang/glog"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
@@ -38,7 +35,7 @@ func runCommand(cmd string, args ...string) error {
 	c := exec.Command(cmd, args...)
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
-	c.Env = append(c.Env, append(os.Environ())...)
+	c.Env = append(c.Env, os.Environ()...)
 	glog.Infof("Running command %q\n", cmd)
 	return c.Run()
 }
@@ -48,13 +45,13 @@ func deleteInForeground() *metav1.DeleteOptions {
 	return &metav1.DeleteOptions{PropagationPolicy: &policy}
 }
 
-func (f Framework) GetResponseFromPod(podName string) (bool, error) {
-	pod, err := f.kubeClient.CoreV1().Pods(f.Namespace()).Get(context.TODO(), podName, metav1.GetOptions{})
+func (i *Invocation) GetResponseFromPod(podName string) (bool, error) {
+	pod, err := i.kubeClient.CoreV1().Pods(i.Namespace()).Get(context.TODO(), podName, metav1.GetOptions{})
 	if err != nil {
 		return false, err
 	}
 
-	resp, err := curlBackendInPod(f.RestConfig(), pod)
+	resp, err := curlBackendInPod(i.RestConfig(), pod)
 	if err != nil {
 		return false, err
 	}
@@ -65,46 +62,22 @@ func curlBackendInPod(config *rest.Config, pod *v1.Pod) (string, error) {
 	return kmodules.ExecIntoPod(config, pod, kmodules.Command("curl", "http://backend", "-s", "-m", "10"))
 }
 
+// GetHTTPResponse gets link and reports whether it answered 200. Requests time
+// out after 10s, use a Probe for anything else.
 func GetHTTPResponse(link string) (bool, string, error) {
-	resp, err := http.Get(link)
-	if err != nil {
-		return false, "", err
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	result, err := NewProbe(link).Do()
+	if result == nil {
 		return false, "", err
 	}
-
-	return resp.StatusCode == 200, string(bodyBytes), nil
+	return result.StatusCode == http.StatusOK, result.Body, nil
 }
 
+// WaitForHTTPResponse waits until link answers 200 with "Hello world" in the
+// body, the first post of a fresh WordPress.
 func (f *Invocation) WaitForHTTPResponse(link string) error {
-	return wait.PollImmediate(f.rootInvocation.RetryInterval, f.rootInvocation.Timeout, func() (bool, error) {
-		ok, resp, err := GetHTTPResponse(link)
-		if err != nil {
-			return false, nil
-		}
-		if ok {
-			log.Println("Got response from " + link)
-			if strings.Contains(resp, "Hello world") {
-				return true, nil
-			}
-			return true, fmt.Errorf("the response didn't have Hello world post in it")
-		}
-
-		return false, nil
-	})
-}
-
-func (f Framework) ApplyManifest(manifestPath string) error {
-	args := []string{"apply", "--kubeconfig", f.kubeConfig, "-f", manifestPath}
-	cmd := exec.Command("kubectl", args...)
-	out, err := cmd.CombinedOutput()
-	fmt.Println(string(out))
-	if err != nil {
+	if err := f.WaitForProbe(NewProbe(link).ExpectBodyContains("Hello world")); err != nil {
 		return err
 	}
+	log.Println("Got response from " + link)
 	return nil
 }
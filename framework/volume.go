@@ -0,0 +1,371 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	kmodules "kmodules.xyz/client-go/tools/exec"
+)
+
+const (
+	// VolumeMountPath is where GetVolumePodObject mounts the claim.
+	VolumeMountPath = "/data"
+
+	hostnameLabel = "kubernetes.io/hostname"
+	volumeName    = "data"
+)
+
+// BlockStorageVolume is the Linode block storage volume backing a
+// PersistentVolume as the Linode API reports it.
+type BlockStorageVolume struct {
+	ID     int
+	Label  string
+	Region string
+	// Size is in GiB.
+	Size   int
+	Status string
+	// LinodeID is the instance the volume is attached to, nil while detached.
+	LinodeID *int
+	Tags     []string
+}
+
+// GetPersistentVolumeClaimObject returns a ReadWriteOnce claim of size, e.g.
+// "10Gi", from the StorageClass named by StorageClass.
+func (i *k8sInvocation) GetPersistentVolumeClaimObject(name, size string) *core.PersistentVolumeClaim {
+	storageClass := StorageClass
+	return &core.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: i.Namespace(),
+		},
+		Spec: core.PersistentVolumeClaimSpec{
+			AccessModes:      []core.PersistentVolumeAccessMode{core.ReadWriteOnce},
+			StorageClassName: &storageClass,
+			Resources: core.ResourceRequirements{
+				Requests: core.ResourceList{
+					core.ResourceStorage: resource.MustParse(size),
+				},
+			},
+		},
+	}
+}
+
+// CreatePersistentVolumeClaim creates the claim and deletes it when the spec
+// ends. Whether its volume goes too depends on the reclaim policy.
+func (i *k8sInvocation) CreatePersistentVolumeClaim(pvc *core.PersistentVolumeClaim) (*core.PersistentVolumeClaim, error) {
+	pvc, err := i.kubeClient.CoreV1().PersistentVolumeClaims(i.Namespace()).Create(context.TODO(), pvc, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	name := pvc.Name
+	i.tracker.track("PersistentVolumeClaim", i.Namespace(), name, func() error { return i.DeletePersistentVolumeClaim(name) })
+
+	return pvc, nil
+}
+
+func (i *k8sInvocation) DeletePersistentVolumeClaim(name string) error {
+	return i.kubeClient.CoreV1().PersistentVolumeClaims(i.Namespace()).Delete(context.TODO(), name, *deleteInForeground())
+}
+
+// CreateStorageClass creates a copy of the StorageClass named by StorageClass
+// with another reclaim policy, e.g. to check that Retain keeps the volume. It
+// is deleted when the spec ends.
+func (i *k8sInvocation) CreateStorageClass(name string, reclaimPolicy core.PersistentVolumeReclaimPolicy) (*storage.StorageClass, error) {
+	ctx := context.TODO()
+	base, err := i.kubeClient.StorageV1().StorageClasses().Get(ctx, StorageClass, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting StorageClass %s", StorageClass)
+	}
+	sc := &storage.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: name},
+		Provisioner:          base.Provisioner,
+		Parameters:           base.Parameters,
+		MountOptions:         base.MountOptions,
+		AllowVolumeExpansion: base.AllowVolumeExpansion,
+		VolumeBindingMode:    base.VolumeBindingMode,
+		ReclaimPolicy:        &reclaimPolicy,
+	}
+	sc, err = i.kubeClient.StorageV1().StorageClasses().Create(ctx, sc, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	i.tracker.track("StorageClass", "", name, func() error {
+		return i.kubeClient.StorageV1().StorageClasses().Delete(context.TODO(), name, metav1.DeleteOptions{})
+	})
+	return sc, nil
+}
+
+// GetVolumePodObject returns an nginx pod that mounts the claim at
+// VolumeMountPath. nodeName pins the pod to that worker unless it is empty.
+func (i *k8sInvocation) GetVolumePodObject(podName, claimName, nodeName string) *core.Pod {
+	pod := i.GetPodObject(podName, nil)
+	pod.Spec.Volumes = []core.Volume{{
+		Name: volumeName,
+		VolumeSource: core.VolumeSource{
+			PersistentVolumeClaim: &core.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+		},
+	}}
+	pod.Spec.Containers[0].VolumeMounts = []core.VolumeMount{{Name: volumeName, MountPath: VolumeMountPath}}
+	if nodeName != "" {
+		pod.Spec.NodeSelector = map[string]string{hostnameLabel: nodeName}
+	}
+	return pod
+}
+
+// WaitForPersistentVolumeClaimBound waits up to Timeouts.Volume for the claim
+// to be bound and returns it. Claims of a WaitForFirstConsumer StorageClass
+// are only bound once a pod uses them.
+func (i *k8sInvocation) WaitForPersistentVolumeClaimBound(name string) (*core.PersistentVolumeClaim, error) {
+	pvc, err := waitForPersistentVolumeClaim(context.TODO(), i.kubeClient, "pvc-bound", i.Timeouts.Volume, i.Namespace(), name, func(pvc *core.PersistentVolumeClaim) (bool, error) {
+		if pvc == nil {
+			return false, nil
+		}
+		if pvc.Status.Phase == core.ClaimLost {
+			return false, errors.Errorf("claim %s lost its volume %s", pvc.Name, pvc.Spec.VolumeName)
+		}
+		return pvc.Status.Phase == core.ClaimBound, nil
+	})
+	if err != nil {
+		return nil, i.describeClaimError(err, name, pvc, "is not bound")
+	}
+	return pvc, nil
+}
+
+// ExpandPersistentVolumeClaim requests size for the claim. Wait for the volume
+// and its filesystem to grow with WaitForPersistentVolumeClaimCapacity.
+func (i *k8sInvocation) ExpandPersistentVolumeClaim(name, size string) error {
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return err
+	}
+	ctx, claims := context.TODO(), i.kubeClient.CoreV1().PersistentVolumeClaims(i.Namespace())
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pvc, err := claims.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		pvc.Spec.Resources.Requests[core.ResourceStorage] = quantity
+		_, err = claims.Update(ctx, pvc, metav1.UpdateOptions{})
+		return err
+	})
+	return errors.Wrapf(err, "expanding claim %s/%s to %s", i.Namespace(), name, size)
+}
+
+// WaitForPersistentVolumeClaimCapacity waits up to Timeouts.Volume until the
+// claim reports at least size. The capacity is only updated once the
+// filesystem was resized as well, which needs a pod using the claim.
+func (i *k8sInvocation) WaitForPersistentVolumeClaimCapacity(name, size string) (*core.PersistentVolumeClaim, error) {
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, err
+	}
+	pvc, err := waitForPersistentVolumeClaim(context.TODO(), i.kubeClient, "pvc-capacity", i.Timeouts.Volume, i.Namespace(), name, func(pvc *core.PersistentVolumeClaim) (bool, error) {
+		if pvc == nil {
+			return false, nil
+		}
+		capacity, ok := pvc.Status.Capacity[core.ResourceStorage]
+		return ok && capacity.Cmp(quantity) >= 0, nil
+	})
+	if err != nil {
+		return nil, i.describeClaimError(err, name, pvc, "has not reached "+size)
+	}
+	return pvc, nil
+}
+
+// describeClaimError explains why a wait for the claim failed, with the last
+// observed status and its recent events.
+func (i *k8sInvocation) describeClaimError(err error, name string, pvc *core.PersistentVolumeClaim, state string) error {
+	msg := fmt.Sprintf("claim %s/%s %s", i.Namespace(), name, state)
+	if err == wait.ErrWaitTimeout {
+		msg += fmt.Sprintf(" after %s", i.Timeouts.Volume)
+	} else {
+		msg += fmt.Sprintf(": %v", err)
+	}
+	if pvc != nil {
+		msg += "\n" + describeClaimStatus(pvc)
+	} else {
+		msg += ", the claim does not exist"
+	}
+	return errors.New(msg + "\n" + i.describeEvents(i.Namespace(), "PersistentVolumeClaim", name))
+}
+
+func describeClaimStatus(pvc *core.PersistentVolumeClaim) string {
+	capacity := pvc.Status.Capacity[core.ResourceStorage]
+	s := fmt.Sprintf("phase %s, volume %q, capacity %s", pvc.Status.Phase, pvc.Spec.VolumeName, capacity.String())
+	for _, c := range pvc.Status.Conditions {
+		s += fmt.Sprintf("\ncondition %s=%s: %s %s", c.Type, c.Status, c.Reason, c.Message)
+	}
+	return s
+}
+
+// GetPersistentVolume returns the volume bound to the claim.
+func (i *k8sInvocation) GetPersistentVolume(claimName string) (*core.PersistentVolume, error) {
+	ctx := context.TODO()
+	pvc, err := i.kubeClient.CoreV1().PersistentVolumeClaims(i.Namespace()).Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if pvc.Spec.VolumeName == "" {
+		return nil, errors.Errorf("claim %s/%s is not bound", i.Namespace(), claimName)
+	}
+	return i.kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+}
+
+// WaitForPersistentVolume waits up to Timeouts.Volume until condition holds
+// for the PersistentVolume, see WaitForPod.
+func (i *k8sInvocation) WaitForPersistentVolume(name string, condition func(*core.PersistentVolume) (bool, error)) (*core.PersistentVolume, error) {
+	pv, err := waitForPersistentVolume(context.TODO(), i.kubeClient, "persistent-volume", i.Timeouts.Volume, name, condition)
+	return pv, errors.Wrapf(err, "waiting for persistent volume %s", name)
+}
+
+// WaitForPersistentVolumeDeleted waits up to Timeouts.Volume until the
+// provisioner deleted the PersistentVolume, i.e. its Linode volume.
+func (i *k8sInvocation) WaitForPersistentVolumeDeleted(name string) error {
+	pv, err := waitForPersistentVolume(context.TODO(), i.kubeClient, "persistent-volume-deleted", i.Timeouts.Volume, name, func(pv *core.PersistentVolume) (bool, error) {
+		if pv != nil && pv.Status.Phase == core.VolumeFailed {
+			return false, errors.Errorf("reclaiming failed: %s", pv.Status.Message)
+		}
+		return pv == nil, nil
+	})
+	if err != nil && pv != nil {
+		return errors.Errorf("persistent volume %s was not deleted: %v, phase %s\n%s", name, err, pv.Status.Phase,
+			i.describeEvents(metav1.NamespaceDefault, "PersistentVolume", name))
+	}
+	return errors.Wrapf(err, "waiting for persistent volume %s to be deleted", name)
+}
+
+// DeletePersistentVolume deletes a PersistentVolume, e.g. one the Retain
+// reclaim policy kept. It leaves the Linode volume alone.
+func (i *k8sInvocation) DeletePersistentVolume(name string) error {
+	return i.kubeClient.CoreV1().PersistentVolumes().Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
+
+// ExecInPod runs command in the first container of the pod and returns its
+// output.
+func (i *k8sInvocation) ExecInPod(podName string, command ...string) (string, error) {
+	pod, err := i.GetPod(podName, i.Namespace())
+	if err != nil {
+		return "", err
+	}
+	out, err := kmodules.ExecIntoPod(i.RestConfig(), pod, kmodules.Command(command...))
+	return out, errors.Wrapf(err, "running %q in pod %s/%s", strings.Join(command, " "), i.Namespace(), podName)
+}
+
+// WriteVolumeFile writes content to file below VolumeMountPath in the pod and
+// flushes it to the volume.
+func (i *k8sInvocation) WriteVolumeFile(podName, file, content string) error {
+	_, err := i.ExecInPod(podName, "sh", "-c", `printf %s "$1" > "$2" && sync`, "sh", content, VolumeMountPath+"/"+file)
+	return err
+}
+
+// ReadVolumeFile reads file below VolumeMountPath in the pod.
+func (i *k8sInvocation) ReadVolumeFile(podName, file string) (string, error) {
+	return i.ExecInPod(podName, "cat", VolumeMountPath+"/"+file)
+}
+
+// VolumeFilesystemSize returns the size in bytes of the filesystem mounted at
+// VolumeMountPath in the pod, as df reports it.
+func (i *k8sInvocation) VolumeFilesystemSize(podName string) (int64, error) {
+	out, err := i.ExecInPod(podName, "df", "-Pk", VolumeMountPath)
+	if err != nil {
+		return 0, err
+	}
+	return parseDFSize(out)
+}
+
+// parseDFSize reads the size of the single filesystem in the output of df -Pk.
+func parseDFSize(out string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		return 0, errors.Errorf("unexpected df output %q", out)
+	}
+	fields := strings.Fields(lines[1])
+	if len(fields) < 2 {
+		return 0, errors.Errorf("unexpected df output %q", out)
+	}
+	blocks, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unexpected df output %q", out)
+	}
+	return blocks * 1024, nil
+}
+
+// GetBlockStorageVolume looks up the Linode volume behind a PersistentVolume
+// of the Linode CSI driver through the Linode API. It returns nil without an
+// error when the volume does not exist.
+func GetBlockStorageVolume(pv *core.PersistentVolume) (*BlockStorageVolume, error) {
+	id, err := blockStorageVolumeID(pv)
+	if err != nil {
+		return nil, err
+	}
+	v, err := linodeAPI().GetVolume(context.TODO(), id)
+	if isAPIStatus(err, 404) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &BlockStorageVolume{
+		ID:       v.ID,
+		Label:    v.Label,
+		Region:   v.Region,
+		Size:     v.Size,
+		Status:   v.Status,
+		LinodeID: v.LinodeID,
+		Tags:     v.Tags,
+	}, nil
+}
+
+// blockStorageVolumeID parses the Linode volume ID from the CSI volume handle,
+// which the driver sets to <id>-<label>.
+func blockStorageVolumeID(pv *core.PersistentVolume) (int, error) {
+	if pv.Spec.CSI == nil {
+		return 0, errors.Errorf("persistent volume %s is not provisioned by a CSI driver", pv.Name)
+	}
+	handle := pv.Spec.CSI.VolumeHandle
+	id, err := strconv.Atoi(strings.SplitN(handle, "-", 2)[0])
+	if err != nil {
+		return 0, errors.Errorf("persistent volume %s has no Linode volume handle: %q", pv.Name, handle)
+	}
+	return id, nil
+}
+
+// WaitForBlockStorageVolume polls the Linode volume behind the
+// PersistentVolume up to Timeouts.Volume until condition holds. condition gets
+// nil once the volume was deleted.
+func (i *k8sInvocation) WaitForBlockStorageVolume(pv *core.PersistentVolume, condition func(*BlockStorageVolume) bool) (*BlockStorageVolume, error) {
+	var v *BlockStorageVolume
+	err := poll("block-storage-volume", i.RetryInterval, i.Timeouts.Volume, func() (bool, error) {
+		var err error
+		if v, err = GetBlockStorageVolume(pv); err != nil {
+			return false, err
+		}
+		return condition(v), nil
+	})
+	return v, errors.Wrapf(err, "waiting for the Linode volume of persistent volume %s", pv.Name)
+}
+
+// DeleteBlockStorageVolume deletes a Linode volume once it is detached, e.g.
+// one the Retain reclaim policy kept. A volume that is already gone is ignored.
+func (i *k8sInvocation) DeleteBlockStorageVolume(pv *core.PersistentVolume) error {
+	v, err := i.WaitForBlockStorageVolume(pv, func(v *BlockStorageVolume) bool {
+		return v == nil || v.LinodeID == nil
+	})
+	if err != nil || v == nil {
+		return err
+	}
+	err = linodeAPI().DeleteVolume(context.TODO(), v.ID)
+	if isAPIStatus(err, 404) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Volumes", func() {
+	var (
+		inv *Invocation
+		ctx = context.TODO()
+	)
+
+	BeforeEach(func() {
+		expand := true
+		var err error
+		inv, err = newFakeFramework(&storage.StorageClass{
+			ObjectMeta:           metav1.ObjectMeta{Name: StorageClass},
+			Provisioner:          "linodebs.csi.linode.com",
+			AllowVolumeExpansion: &expand,
+		}).Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	setClaimStatus := func(name string, status core.PersistentVolumeClaimStatus) {
+		defer GinkgoRecover()
+		pvc, err := inv.kubeClient.CoreV1().PersistentVolumeClaims(inv.Namespace()).Get(ctx, name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		pvc.Status = status
+		_, err = inv.kubeClient.CoreV1().PersistentVolumeClaims(inv.Namespace()).UpdateStatus(ctx, pvc, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("builds claims from the configured StorageClass and pods that mount them", func() {
+		pvc := inv.Cluster.GetPersistentVolumeClaimObject("data", "10Gi")
+		Expect(*pvc.Spec.StorageClassName).To(Equal(StorageClass))
+		Expect(pvc.Spec.AccessModes).To(ConsistOf(core.ReadWriteOnce))
+		Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("10Gi"))
+
+		pod := inv.Cluster.GetVolumePodObject("writer", "data", "node-2")
+		Expect(pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("data"))
+		Expect(pod.Spec.Containers[0].VolumeMounts).To(ConsistOf(core.VolumeMount{Name: "data", MountPath: VolumeMountPath}))
+		Expect(pod.Spec.NodeSelector).To(Equal(map[string]string{hostnameLabel: "node-2"}))
+		Expect(inv.Cluster.GetVolumePodObject("any", "data", "").Spec.NodeSelector).To(BeEmpty())
+	})
+
+	It("waits for claims to be bound and expanded", func() {
+		_, err := inv.Cluster.CreatePersistentVolumeClaim(inv.Cluster.GetPersistentVolumeClaimObject("data", "10Gi"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Resources()).To(ConsistOf(HaveField("Kind", "PersistentVolumeClaim")))
+
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			setClaimStatus("data", core.PersistentVolumeClaimStatus{
+				Phase:    core.ClaimBound,
+				Capacity: core.ResourceList{core.ResourceStorage: resource.MustParse("10Gi")},
+			})
+		}()
+		pvc, err := inv.Cluster.WaitForPersistentVolumeClaimBound("data")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pvc.Status.Phase).To(Equal(core.ClaimBound))
+
+		Expect(inv.Cluster.ExpandPersistentVolumeClaim("data", "20Gi")).To(Succeed())
+		pvc, err = inv.kubeClient.CoreV1().PersistentVolumeClaims(inv.Namespace()).Get(ctx, "data", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("20Gi"))
+
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			setClaimStatus("data", core.PersistentVolumeClaimStatus{
+				Phase:    core.ClaimBound,
+				Capacity: core.ResourceList{core.ResourceStorage: resource.MustParse("20Gi")},
+			})
+		}()
+		_, err = inv.Cluster.WaitForPersistentVolumeClaimCapacity("data", "20Gi")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(inv.Cleanup()).To(Succeed())
+		claims, err := inv.kubeClient.CoreV1().PersistentVolumeClaims(inv.Namespace()).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims.Items).To(BeEmpty())
+	})
+
+	It("fails fast on lost claims and describes pending ones on timeout", func() {
+		pvc := inv.Cluster.GetPersistentVolumeClaimObject("lost", "10Gi")
+		pvc.Status.Phase = core.ClaimLost
+		_, err := inv.Cluster.CreatePersistentVolumeClaim(pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		start := time.Now()
+		_, err = inv.Cluster.WaitForPersistentVolumeClaimBound("lost")
+		Expect(err).To(MatchError(ContainSubstring("lost its volume")))
+		Expect(time.Since(start)).To(BeNumerically("<", inv.Timeouts.Volume))
+
+		inv.Timeouts.Volume = 200 * time.Millisecond
+		pvc = inv.Cluster.GetPersistentVolumeClaimObject("pending", "10Gi")
+		pvc.Status.Phase = core.ClaimPending
+		_, err = inv.Cluster.CreatePersistentVolumeClaim(pvc)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inv.kubeClient.CoreV1().Events(inv.Namespace()).Create(ctx, &core.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "pending.ProvisioningFailed"},
+			InvolvedObject: core.ObjectReference{Kind: "PersistentVolumeClaim", Name: "pending"},
+			Type:           core.EventTypeWarning,
+			Reason:         "ProvisioningFailed",
+			Message:        "failed to provision volume: volume limit reached",
+		}, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = inv.Cluster.WaitForPersistentVolumeClaimBound("pending")
+		Expect(err).To(MatchError(ContainSubstring("claim " + inv.Namespace() + "/pending is not bound after 200ms")))
+		Expect(err).To(MatchError(ContainSubstring("phase Pending")))
+		Expect(err).To(MatchError(ContainSubstring("volume limit reached")))
+	})
+
+	It("copies the StorageClass with another reclaim policy", func() {
+		sc, err := inv.Cluster.CreateStorageClass("retain", core.PersistentVolumeReclaimRetain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sc.Provisioner).To(Equal("linodebs.csi.linode.com"))
+		Expect(*sc.AllowVolumeExpansion).To(BeTrue())
+		Expect(*sc.ReclaimPolicy).To(Equal(core.PersistentVolumeReclaimRetain))
+
+		Expect(inv.Cleanup()).To(Succeed())
+		_, err = inv.kubeClient.StorageV1().StorageClasses().Get(ctx, "retain", metav1.GetOptions{})
+		Expect(isNotFound(err)).To(BeTrue())
+	})
+
+	It("waits for reclaimed volumes to be deleted", func() {
+		client := inv.kubeClient.(*fake.Clientset)
+		Expect(client.Tracker().Add(&core.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"}})).To(Succeed())
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(300 * time.Millisecond)
+			Expect(inv.Cluster.DeletePersistentVolume("pvc-1")).To(Succeed())
+		}()
+		Expect(inv.Cluster.WaitForPersistentVolumeDeleted("pvc-1")).To(Succeed())
+
+		Expect(client.Tracker().Add(&core.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-2"},
+			Status:     core.PersistentVolumeStatus{Phase: core.VolumeFailed, Message: "error deleting volume: 500"},
+		})).To(Succeed())
+		Expect(inv.Cluster.WaitForPersistentVolumeDeleted("pvc-2")).To(MatchError(ContainSubstring("error deleting volume")))
+	})
+
+	It("parses the filesystem size from df", func() {
+		size, err := parseDFSize("Filesystem     1024-blocks  Used Available Capacity Mounted on\n/dev/sdc          10255636 36888  10202364       1% /data\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(BeEquivalentTo(10255636 * 1024))
+
+		_, err = parseDFSize("df: /data: No such file or directory")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Block storage volume lookup", func() {
+	var (
+		api *fakeLinodeAPI
+		inv *Invocation
+	)
+
+	BeforeEach(func() {
+		api = newFakeLinodeAPI()
+		DeferCleanup(api.Close)
+		DeferCleanup(func(url, token string) { LinodeURL, ApiToken = url, token }, LinodeURL, ApiToken)
+		LinodeURL, ApiToken = api.URL, "fake-token"
+
+		var err error
+		inv, err = newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	csiVolume := func(handle string) *core.PersistentVolume {
+		return &core.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+			Spec: core.PersistentVolumeSpec{PersistentVolumeSource: core.PersistentVolumeSource{
+				CSI: &core.CSIPersistentVolumeSource{Driver: "linodebs.csi.linode.com", VolumeHandle: handle},
+			}},
+		}
+	}
+
+	It("finds the Linode volume by the CSI volume handle", func() {
+		linodeID := 42
+		id := api.addVolume(volume{Label: "pvc1abc", Region: "eu-west", Size: 10, Status: "active", LinodeID: &linodeID}, time.Now())
+
+		v, err := GetBlockStorageVolume(csiVolume(fmt.Sprintf("%d-pvc1abc", id)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v.ID).To(Equal(id))
+		Expect(v.Size).To(Equal(10))
+		Expect(*v.LinodeID).To(Equal(42))
+
+		v, err = GetBlockStorageVolume(csiVolume("999-gone"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(BeNil())
+
+		_, err = GetBlockStorageVolume(csiVolume("not-a-linode-volume"))
+		Expect(err).To(MatchError(ContainSubstring("has no Linode volume handle")))
+		_, err = GetBlockStorageVolume(&core.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "host"}})
+		Expect(err).To(MatchError(ContainSubstring("not provisioned by a CSI driver")))
+	})
+
+	It("deletes retained volumes once they are detached", func() {
+		linodeID := 42
+		id := api.addVolume(volume{Label: "pvc1abc", LinodeID: &linodeID}, time.Now())
+		pv := csiVolume(fmt.Sprintf("%d-pvc1abc", id))
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			api.mu.Lock()
+			defer api.mu.Unlock()
+			api.volumes[id].LinodeID = nil
+		}()
+		Expect(inv.Cluster.DeleteBlockStorageVolume(pv)).To(Succeed())
+		Expect(api.volumes).NotTo(HaveKey(id))
+
+		By("ignoring volumes that are already gone")
+		Expect(inv.Cluster.DeleteBlockStorageVolume(pv)).To(Succeed())
+	})
+})
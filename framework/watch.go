@@ -0,0 +1,301 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// watchCondition decides whether a watched object reached the awaited state.
+// obj is nil while the object does not exist.
+type watchCondition func(obj runtime.Object) (bool, error)
+
+// waitFor watches the object namespace/name listed by lw until condition
+// holds. Unlike poll it issues no requests while nothing changes and reacts to
+// changes right away. Expired watches are resumed, and the informer relists
+// when it missed events. It returns the last observed object, nil when it did
+// not exist, and wait.ErrWaitTimeout when the timeout expired first. The number
+// of observed states is recorded like the attempts of poll.
+func waitFor(ctx context.Context, operation string, timeout time.Duration, lw cache.ListerWatcher, objType runtime.Object, namespace, name string, condition watchCondition) (runtime.Object, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		last     runtime.Object
+		attempts int
+		start    = time.Now()
+	)
+	check := func(obj runtime.Object) (bool, error) {
+		attempts++
+		last = obj
+		return condition(obj)
+	}
+
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	_, err := watchtools.UntilWithSync(ctx, lw, objType,
+		func(store cache.Store) (bool, error) {
+			obj, exists, err := store.GetByKey(key)
+			if err != nil {
+				return false, err
+			}
+			if !exists {
+				return check(nil)
+			}
+			return check(obj.(runtime.Object))
+		},
+		func(event watch.Event) (bool, error) {
+			// The list watch selects the object by name, but fake clients
+			// ignore field selectors.
+			m, err := meta.Accessor(event.Object)
+			if err != nil || m.GetNamespace() != namespace || m.GetName() != name {
+				return false, nil
+			}
+			if event.Type == watch.Deleted {
+				return check(nil)
+			}
+			return check(event.Object)
+		},
+	)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		// Also covers an informer that did not sync in time.
+		err = wait.ErrWaitTimeout
+		if attempts == 0 {
+			last = listObject(lw, namespace, name)
+		}
+	}
+
+	recordRetries(RetryCount{
+		Operation:       operation,
+		Attempts:        attempts,
+		DurationSeconds: time.Since(start).Seconds(),
+		TimedOut:        err == wait.ErrWaitTimeout && ctx.Err() == context.DeadlineExceeded,
+	})
+	return last, err
+}
+
+// listObject lists the object for the error of a wait that timed out before it
+// observed anything. It returns nil when the object can't be found.
+func listObject(lw cache.ListerWatcher, namespace, name string) runtime.Object {
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return nil
+	}
+	for _, obj := range objs {
+		if m, err := meta.Accessor(obj); err == nil && m.GetNamespace() == namespace && m.GetName() == name {
+			return obj
+		}
+	}
+	return nil
+}
+
+// nameListWatch lists and watches the single object called name.
+func nameListWatch(name string, list func(metav1.ListOptions) (runtime.Object, error), watchFunc func(metav1.ListOptions) (watch.Interface, error)) *cache.ListWatch {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return list(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return watchFunc(options)
+		},
+	}
+}
+
+func waitForPod(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, namespace, name string, condition func(*core.Pod) (bool, error)) (*core.Pod, error) {
+	pods := client.CoreV1().Pods(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return pods.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return pods.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &core.Pod{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		pod, _ := obj.(*core.Pod)
+		return condition(pod)
+	})
+	pod, _ := obj.(*core.Pod)
+	return pod, err
+}
+
+func waitForService(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, namespace, name string, condition func(*core.Service) (bool, error)) (*core.Service, error) {
+	services := client.CoreV1().Services(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return services.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return services.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &core.Service{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		svc, _ := obj.(*core.Service)
+		return condition(svc)
+	})
+	svc, _ := obj.(*core.Service)
+	return svc, err
+}
+
+func waitForDeployment(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, namespace, name string, condition func(*apps.Deployment) (bool, error)) (*apps.Deployment, error) {
+	deployments := client.AppsV1().Deployments(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return deployments.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return deployments.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &apps.Deployment{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		deploy, _ := obj.(*apps.Deployment)
+		return condition(deploy)
+	})
+	deploy, _ := obj.(*apps.Deployment)
+	return deploy, err
+}
+
+func waitForDaemonSet(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, namespace, name string, condition func(*apps.DaemonSet) (bool, error)) (*apps.DaemonSet, error) {
+	daemonSets := client.AppsV1().DaemonSets(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return daemonSets.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return daemonSets.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &apps.DaemonSet{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		ds, _ := obj.(*apps.DaemonSet)
+		return condition(ds)
+	})
+	ds, _ := obj.(*apps.DaemonSet)
+	return ds, err
+}
+
+func waitForStatefulSet(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, namespace, name string, condition func(*apps.StatefulSet) (bool, error)) (*apps.StatefulSet, error) {
+	statefulSets := client.AppsV1().StatefulSets(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return statefulSets.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return statefulSets.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &apps.StatefulSet{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		sts, _ := obj.(*apps.StatefulSet)
+		return condition(sts)
+	})
+	sts, _ := obj.(*apps.StatefulSet)
+	return sts, err
+}
+
+func waitForJob(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, namespace, name string, condition func(*batch.Job) (bool, error)) (*batch.Job, error) {
+	jobs := client.BatchV1().Jobs(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return jobs.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return jobs.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &batch.Job{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		job, _ := obj.(*batch.Job)
+		return condition(job)
+	})
+	job, _ := obj.(*batch.Job)
+	return job, err
+}
+
+func waitForPersistentVolumeClaim(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, namespace, name string, condition func(*core.PersistentVolumeClaim) (bool, error)) (*core.PersistentVolumeClaim, error) {
+	claims := client.CoreV1().PersistentVolumeClaims(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return claims.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return claims.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &core.PersistentVolumeClaim{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		pvc, _ := obj.(*core.PersistentVolumeClaim)
+		return condition(pvc)
+	})
+	pvc, _ := obj.(*core.PersistentVolumeClaim)
+	return pvc, err
+}
+
+func waitForPersistentVolume(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, name string, condition func(*core.PersistentVolume) (bool, error)) (*core.PersistentVolume, error) {
+	volumes := client.CoreV1().PersistentVolumes()
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return volumes.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return volumes.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &core.PersistentVolume{}, "", name, func(obj runtime.Object) (bool, error) {
+		pv, _ := obj.(*core.PersistentVolume)
+		return condition(pv)
+	})
+	pv, _ := obj.(*core.PersistentVolume)
+	return pv, err
+}
+
+func waitForNamespace(ctx context.Context, client kubernetes.Interface, operation string, timeout time.Duration, name string, condition func(*core.Namespace) (bool, error)) (*core.Namespace, error) {
+	namespaces := client.CoreV1().Namespaces()
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return namespaces.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return namespaces.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &core.Namespace{}, "", name, func(obj runtime.Object) (bool, error) {
+		ns, _ := obj.(*core.Namespace)
+		return condition(ns)
+	})
+	ns, _ := obj.(*core.Namespace)
+	return ns, err
+}
+
+// waitForUnstructured waits for an object of a kind without a typed client,
+// namespace is empty for cluster scoped kinds.
+func waitForUnstructured(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource, operation string, timeout time.Duration, namespace, name string, condition func(*unstructured.Unstructured) (bool, error)) (*unstructured.Unstructured, error) {
+	var resource dynamic.ResourceInterface = client.Resource(gvr)
+	if namespace != "" {
+		resource = client.Resource(gvr).Namespace(namespace)
+	}
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return resource.List(ctx, options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return resource.Watch(ctx, options) },
+	)
+	obj, err := waitFor(ctx, operation, timeout, lw, &unstructured.Unstructured{}, namespace, name, func(obj runtime.Object) (bool, error) {
+		u, _ := obj.(*unstructured.Unstructured)
+		return condition(u)
+	})
+	u, _ := obj.(*unstructured.Unstructured)
+	return u, err
+}
+
+// WaitForPod waits up to Timeout until condition holds for the pod in the
+// invocation namespace and returns the last observed pod. condition gets nil
+// while the pod does not exist.
+func (i *k8sInvocation) WaitForPod(name string, condition func(*core.Pod) (bool, error)) (*core.Pod, error) {
+	pod, err := waitForPod(context.TODO(), i.kubeClient, "pod", i.Timeout, i.Namespace(), name, condition)
+	return pod, errors.Wrapf(err, "waiting for pod %s/%s", i.Namespace(), name)
+}
+
+// WaitForService waits up to Timeout until condition holds for the Service in
+// the invocation namespace, see WaitForPod.
+func (i *k8sInvocation) WaitForService(name string, condition func(*core.Service) (bool, error)) (*core.Service, error) {
+	svc, err := waitForService(context.TODO(), i.kubeClient, "service", i.Timeout, i.Namespace(), name, condition)
+	return svc, errors.Wrapf(err, "waiting for service %s/%s", i.Namespace(), name)
+}
+
+// WaitForDeployment waits up to Timeout until condition holds for the
+// Deployment in the invocation namespace, see WaitForPod.
+func (i *k8sInvocation) WaitForDeployment(name string, condition func(*apps.Deployment) (bool, error)) (*apps.Deployment, error) {
+	deploy, err := waitForDeployment(context.TODO(), i.kubeClient, "deployment", i.Timeout, i.Namespace(), name, condition)
+	return deploy, errors.Wrapf(err, "waiting for deployment %s/%s", i.Namespace(), name)
+}
+
+// WaitForNamespace waits up to Timeout until condition holds for the
+// namespace, see WaitForPod.
+func (f *Framework) WaitForNamespace(name string, condition func(*core.Namespace) (bool, error)) (*core.Namespace, error) {
+	ns, err := waitForNamespace(context.TODO(), f.kubeClient, "namespace", Timeout, name, condition)
+	return ns, errors.Wrapf(err, "waiting for namespace %s", name)
+}
@@ -0,0 +1,181 @@
+package framework
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Watch-based waiting", func() {
+	const namespace = "watched"
+	ctx := context.TODO()
+
+	var client *fake.Clientset
+
+	BeforeEach(func() {
+		client = fake.NewSimpleClientset(&core.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace},
+			Status:     core.PodStatus{Phase: core.PodPending},
+		})
+	})
+
+	running := func(pod *core.Pod) (bool, error) {
+		return pod != nil && pod.Status.Phase == core.PodRunning, nil
+	}
+
+	// setPhase updates the pod after a delay, while a wait is watching it.
+	setPhase := func(delay time.Duration, phase core.PodPhase) {
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(delay)
+			// The tracker records no actions, unlike the clientset.
+			obj, err := client.Tracker().Get(core.SchemeGroupVersion.WithResource("pods"), namespace, "web")
+			Expect(err).NotTo(HaveOccurred())
+			pod := obj.(*core.Pod).DeepCopy()
+			pod.Status.Phase = phase
+			Expect(client.Tracker().Update(core.SchemeGroupVersion.WithResource("pods"), pod, namespace)).To(Succeed())
+		}()
+	}
+
+	It("reacts to updates without polling", func() {
+		setPhase(200*time.Millisecond, core.PodRunning)
+
+		pod, err := waitForPod(ctx, client, "pod", 5*time.Second, namespace, "web", running)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pod.Status.Phase).To(Equal(core.PodRunning))
+
+		for _, action := range client.Actions() {
+			Expect(action.GetVerb()).To(BeElementOf("list", "watch"))
+		}
+	})
+
+	It("succeeds right away when the condition already holds", func() {
+		pod, err := waitForPod(ctx, client, "pod", 5*time.Second, namespace, "web", func(pod *core.Pod) (bool, error) {
+			return pod != nil && pod.Status.Phase == core.PodPending, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pod.Name).To(Equal("web"))
+	})
+
+	It("ignores other objects of the same kind", func() {
+		other := &core.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: namespace},
+			Status:     core.PodStatus{Phase: core.PodRunning},
+		}
+		_, err := client.CoreV1().Pods(namespace).Create(ctx, other, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		pod, err := waitForPod(ctx, client, "pod", 300*time.Millisecond, namespace, "web", running)
+		Expect(err).To(Equal(wait.ErrWaitTimeout))
+		Expect(pod.Name).To(Equal("web"))
+		Expect(pod.Status.Phase).To(Equal(core.PodPending))
+	})
+
+	It("resumes after the watch expired", func() {
+		var watches int32
+		expired := watch.NewFake()
+		client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+			if atomic.AddInt32(&watches, 1) == 1 {
+				return true, expired, nil
+			}
+			return false, nil, nil
+		})
+
+		go func() {
+			defer GinkgoRecover()
+			Eventually(func() int32 { return atomic.LoadInt32(&watches) }).Should(BeEquivalentTo(1))
+			expired.Error(&kerr.NewResourceExpired("too old resource version").ErrStatus)
+		}()
+		setPhase(300*time.Millisecond, core.PodRunning)
+
+		_, err := waitForPod(ctx, client, "pod", 5*time.Second, namespace, "web", running)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt32(&watches)).To(BeNumerically(">=", 2))
+	})
+
+	It("passes nil for deleted objects", func() {
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(200 * time.Millisecond)
+			Expect(client.CoreV1().Pods(namespace).Delete(ctx, "web", metav1.DeleteOptions{})).To(Succeed())
+		}()
+
+		pod, err := waitForPod(ctx, client, "pod", 5*time.Second, namespace, "web", func(pod *core.Pod) (bool, error) {
+			return pod == nil, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pod).To(BeNil())
+	})
+
+	It("stops on condition errors", func() {
+		pod, err := waitForPod(ctx, client, "pod", 5*time.Second, namespace, "web", func(pod *core.Pod) (bool, error) {
+			return false, kerr.NewBadRequest("pod is pending")
+		})
+		Expect(err).To(MatchError("pod is pending"))
+		Expect(pod.Name).To(Equal("web"))
+	})
+
+	It("returns the last observed object on timeout", func() {
+		pod, err := waitForPod(ctx, client, "pod", 50*time.Millisecond, namespace, "web", running)
+		Expect(err).To(Equal(wait.ErrWaitTimeout))
+		Expect(pod.Status.Phase).To(Equal(core.PodPending))
+	})
+
+	It("waits for Services, Deployments and namespaces", func() {
+		inv, err := newFakeFramework().Invoke(WithIsolatedNamespace())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inv.Cluster.NewService("lb").Create()
+		Expect(err).NotTo(HaveOccurred())
+		deploy := &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: inv.Namespace()}}
+		_, err = inv.kubeClient.AppsV1().Deployments(inv.Namespace()).Create(ctx, deploy, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		svc, err := inv.Cluster.WaitForService("lb", func(svc *core.Service) (bool, error) {
+			return svc != nil, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(svc.Spec.Type).To(Equal(core.ServiceTypeLoadBalancer))
+
+		_, err = inv.Cluster.WaitForDeployment("web", func(d *apps.Deployment) (bool, error) {
+			return d != nil && d.Status.ReadyReplicas > 0, nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("waiting for deployment " + inv.Namespace() + "/web")))
+
+		ns, err := inv.WaitForNamespace(inv.Namespace(), func(ns *core.Namespace) (bool, error) {
+			return ns != nil && ns.Status.Phase == core.NamespaceActive, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ns.Name).To(Equal(inv.Namespace()))
+	})
+
+	It("records the observed states on the spec report", func() {
+		setPhase(300*time.Millisecond, core.PodRunning)
+		_, err := waitForPod(ctx, client, "watch-test", 5*time.Second, namespace, "web", running)
+		Expect(err).NotTo(HaveOccurred())
+
+		var retries []RetryCount
+		for _, entry := range CurrentSpecReport().ReportEntries {
+			if entry.Name == retriesEntry {
+				var r RetryCount
+				Expect(decodeEntry(entry, &r)).To(Succeed())
+				retries = append(retries, r)
+			}
+		}
+		Expect(retries).To(ContainElement(And(
+			HaveField("Operation", "watch-test"),
+			HaveField("Attempts", BeNumerically(">=", 2)),
+			HaveField("TimedOut", false),
+		)))
+	})
+})
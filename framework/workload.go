@@ -0,0 +1,452 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// WorkloadKind is a kind of pod controller a WorkloadBuilder can create.
+type WorkloadKind string
+
+const (
+	KindDeployment  WorkloadKind = "Deployment"
+	KindStatefulSet WorkloadKind = "StatefulSet"
+	KindDaemonSet   WorkloadKind = "DaemonSet"
+	KindJob         WorkloadKind = "Job"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl rollout restart
+// sets.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// WorkloadBuilder builds a Deployment, StatefulSet, DaemonSet or Job for a
+// spec. The pods run nginx on port 80 like GetPodObject unless the template is
+// changed. Start with NewWorkload, chain the With* methods and finish with one
+// of the Create methods:
+//
+//	deploy, err := f.Cluster.NewWorkload("web", labels).
+//		WithReplicas(3).
+//		CreateDeployment()
+//	err = f.Cluster.WaitForRollout(framework.KindDeployment, "web")
+type WorkloadBuilder struct {
+	i            *k8sInvocation
+	name         string
+	labels       map[string]string
+	replicas     int32
+	template     core.PodTemplateSpec
+	serviceName  string
+	claims       []core.PersistentVolumeClaim
+	completions  *int32
+	backoffLimit *int32
+}
+
+// NewWorkload starts a workload in the namespace of the Invocation. labels
+// select its pods and label the workload and the pods.
+func (i *k8sInvocation) NewWorkload(name string, labels map[string]string) *WorkloadBuilder {
+	pod := i.GetPodObject(name, labels)
+	return &WorkloadBuilder{
+		i:        i,
+		name:     name,
+		labels:   labels,
+		replicas: 1,
+		template: core.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Spec:       pod.Spec,
+		},
+	}
+}
+
+// WithReplicas sets the replicas of Deployments and StatefulSets and the
+// parallelism of Jobs.
+func (b *WorkloadBuilder) WithReplicas(replicas int32) *WorkloadBuilder {
+	b.replicas = replicas
+	return b
+}
+
+// WithImage replaces the image of the first container.
+func (b *WorkloadBuilder) WithImage(image string) *WorkloadBuilder {
+	b.template.Spec.Containers[0].Image = image
+	return b
+}
+
+// WithCommand replaces the command of the first container.
+func (b *WorkloadBuilder) WithCommand(command ...string) *WorkloadBuilder {
+	b.template.Spec.Containers[0].Command = command
+	return b
+}
+
+// WithPodSpec lets mutate change the pod template, e.g. to add volumes or
+// node selectors.
+func (b *WorkloadBuilder) WithPodSpec(mutate func(spec *core.PodSpec)) *WorkloadBuilder {
+	mutate(&b.template.Spec)
+	return b
+}
+
+// WithServiceName sets the governing Service of a StatefulSet, the workload
+// name by default.
+func (b *WorkloadBuilder) WithServiceName(name string) *WorkloadBuilder {
+	b.serviceName = name
+	return b
+}
+
+// WithVolumeClaimTemplate adds a volume claim template to a StatefulSet and
+// mounts it into the first container at mountPath.
+func (b *WorkloadBuilder) WithVolumeClaimTemplate(claim core.PersistentVolumeClaim, mountPath string) *WorkloadBuilder {
+	b.claims = append(b.claims, claim)
+	container := &b.template.Spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, core.VolumeMount{Name: claim.Name, MountPath: mountPath})
+	return b
+}
+
+// WithCompletions sets how many pods of a Job have to succeed.
+func (b *WorkloadBuilder) WithCompletions(completions int32) *WorkloadBuilder {
+	b.completions = &completions
+	return b
+}
+
+// WithBackoffLimit sets how often a Job retries failed pods.
+func (b *WorkloadBuilder) WithBackoffLimit(limit int32) *WorkloadBuilder {
+	b.backoffLimit = &limit
+	return b
+}
+
+func (b *WorkloadBuilder) objectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: b.name, Namespace: b.i.Namespace(), Labels: b.labels}
+}
+
+func (b *WorkloadBuilder) podTemplate() core.PodTemplateSpec {
+	return *b.template.DeepCopy()
+}
+
+func (b *WorkloadBuilder) DeploymentObject() *apps.Deployment {
+	replicas := b.replicas
+	return &apps.Deployment{
+		ObjectMeta: b.objectMeta(),
+		Spec: apps.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: b.labels},
+			Template: b.podTemplate(),
+		},
+	}
+}
+
+func (b *WorkloadBuilder) StatefulSetObject() *apps.StatefulSet {
+	replicas := b.replicas
+	serviceName := b.serviceName
+	if serviceName == "" {
+		serviceName = b.name
+	}
+	claims := make([]core.PersistentVolumeClaim, len(b.claims))
+	for idx := range b.claims {
+		claims[idx] = *b.claims[idx].DeepCopy()
+	}
+	return &apps.StatefulSet{
+		ObjectMeta: b.objectMeta(),
+		Spec: apps.StatefulSetSpec{
+			Replicas:             &replicas,
+			ServiceName:          serviceName,
+			Selector:             &metav1.LabelSelector{MatchLabels: b.labels},
+			Template:             b.podTemplate(),
+			VolumeClaimTemplates: claims,
+		},
+	}
+}
+
+func (b *WorkloadBuilder) DaemonSetObject() *apps.DaemonSet {
+	return &apps.DaemonSet{
+		ObjectMeta: b.objectMeta(),
+		Spec: apps.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: b.labels},
+			Template: b.podTemplate(),
+		},
+	}
+}
+
+// JobObject returns a Job whose pods are not restarted in place, failed pods
+// are replaced up to the backoff limit.
+func (b *WorkloadBuilder) JobObject() *batch.Job {
+	parallelism := b.replicas
+	template := b.podTemplate()
+	template.Spec.RestartPolicy = core.RestartPolicyNever
+	return &batch.Job{
+		ObjectMeta: b.objectMeta(),
+		Spec: batch.JobSpec{
+			Parallelism:  &parallelism,
+			Completions:  b.completions,
+			BackoffLimit: b.backoffLimit,
+			Template:     template,
+		},
+	}
+}
+
+// CreateDeployment creates the Deployment and deletes it when the spec ends.
+func (b *WorkloadBuilder) CreateDeployment() (*apps.Deployment, error) {
+	deploy, err := b.i.kubeClient.AppsV1().Deployments(b.i.Namespace()).Create(context.TODO(), b.DeploymentObject(), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b.i.trackWorkload(KindDeployment, deploy.Name)
+	return deploy, nil
+}
+
+// CreateStatefulSet creates the StatefulSet and deletes it when the spec ends,
+// followed by the PersistentVolumeClaims of its volume claim templates.
+func (b *WorkloadBuilder) CreateStatefulSet() (*apps.StatefulSet, error) {
+	obj := b.StatefulSetObject()
+	// Tracked first so the claims are deleted after the StatefulSet.
+	b.i.trackStatefulSetClaims(obj, 0, *obj.Spec.Replicas)
+	sts, err := b.i.kubeClient.AppsV1().StatefulSets(b.i.Namespace()).Create(context.TODO(), obj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b.i.trackWorkload(KindStatefulSet, sts.Name)
+	return sts, nil
+}
+
+// CreateDaemonSet creates the DaemonSet and deletes it when the spec ends.
+func (b *WorkloadBuilder) CreateDaemonSet() (*apps.DaemonSet, error) {
+	ds, err := b.i.kubeClient.AppsV1().DaemonSets(b.i.Namespace()).Create(context.TODO(), b.DaemonSetObject(), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b.i.trackWorkload(KindDaemonSet, ds.Name)
+	return ds, nil
+}
+
+// CreateJob creates the Job and deletes it with its pods when the spec ends.
+func (b *WorkloadBuilder) CreateJob() (*batch.Job, error) {
+	job, err := b.i.kubeClient.BatchV1().Jobs(b.i.Namespace()).Create(context.TODO(), b.JobObject(), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b.i.trackWorkload(KindJob, job.Name)
+	return job, nil
+}
+
+// trackStatefulSetClaims tracks the claims the StatefulSet controller creates
+// for the replicas from up to to, <template>-<statefulset>-<ordinal>.
+func (i *k8sInvocation) trackStatefulSetClaims(sts *apps.StatefulSet, from, to int32) {
+	for ordinal := from; ordinal < to; ordinal++ {
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			name := fmt.Sprintf("%s-%s-%d", template.Name, sts.Name, ordinal)
+			i.tracker.track("PersistentVolumeClaim", i.Namespace(), name, func() error { return i.DeletePersistentVolumeClaim(name) })
+		}
+	}
+}
+
+func (i *k8sInvocation) trackWorkload(kind WorkloadKind, name string) {
+	i.tracker.track(string(kind), i.Namespace(), name, func() error { return i.DeleteWorkload(kind, name) })
+}
+
+// DeleteWorkload deletes the workload and, in the foreground, its pods.
+func (i *k8sInvocation) DeleteWorkload(kind WorkloadKind, name string) error {
+	ctx, ns, opts := context.TODO(), i.Namespace(), *deleteInForeground()
+	switch kind {
+	case KindDeployment:
+		return i.kubeClient.AppsV1().Deployments(ns).Delete(ctx, name, opts)
+	case KindStatefulSet:
+		return i.kubeClient.AppsV1().StatefulSets(ns).Delete(ctx, name, opts)
+	case KindDaemonSet:
+		return i.kubeClient.AppsV1().DaemonSets(ns).Delete(ctx, name, opts)
+	case KindJob:
+		return i.kubeClient.BatchV1().Jobs(ns).Delete(ctx, name, opts)
+	}
+	return errors.Errorf("unknown workload kind %s", kind)
+}
+
+// ListPods lists the pods of a workload in the invocation namespace by the
+// labels it was built with, e.g. to find the node to drain.
+func (i *k8sInvocation) ListPods(labels map[string]string) ([]core.Pod, error) {
+	pods, err := i.kubeClient.CoreV1().Pods(i.Namespace()).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: labels}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// Scale sets the replicas of a Deployment or StatefulSet. Wait for the pods
+// with WaitForRollout. The claims of added StatefulSet replicas are deleted
+// when the spec ends.
+func (i *k8sInvocation) Scale(kind WorkloadKind, name string, replicas int32) error {
+	ctx, ns := context.TODO(), i.Namespace()
+	var scaled *apps.StatefulSet
+	var from int32
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		switch kind {
+		case KindDeployment:
+			deploy, err := i.kubeClient.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			deploy.Spec.Replicas = &replicas
+			_, err = i.kubeClient.AppsV1().Deployments(ns).Update(ctx, deploy, metav1.UpdateOptions{})
+			return err
+		case KindStatefulSet:
+			sts, err := i.kubeClient.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if sts.Spec.Replicas != nil {
+				from = *sts.Spec.Replicas
+			}
+			sts.Spec.Replicas = &replicas
+			scaled, err = i.kubeClient.AppsV1().StatefulSets(ns).Update(ctx, sts, metav1.UpdateOptions{})
+			return err
+		}
+		return errors.Errorf("%s can't be scaled", kind)
+	})
+	if err == nil && scaled != nil {
+		i.trackStatefulSetClaims(scaled, from, replicas)
+	}
+	return errors.Wrapf(err, "scaling %s %s to %d", kind, name, replicas)
+}
+
+// Restart replaces all pods of a Deployment, StatefulSet or DaemonSet with a
+// rolling update, like kubectl rollout restart. Wait for it with
+// WaitForRollout.
+func (i *k8sInvocation) Restart(kind WorkloadKind, name string) error {
+	return i.updateTemplate(kind, name, func(template *core.PodTemplateSpec) {
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[restartedAtAnnotation] = time.Now().Format(time.RFC3339Nano)
+	})
+}
+
+// SetImage rolls the first container of a Deployment, StatefulSet or
+// DaemonSet to image.
+func (i *k8sInvocation) SetImage(kind WorkloadKind, name, image string) error {
+	return i.updateTemplate(kind, name, func(template *core.PodTemplateSpec) {
+		template.Spec.Containers[0].Image = image
+	})
+}
+
+func (i *k8sInvocation) updateTemplate(kind WorkloadKind, name string, mutate func(*core.PodTemplateSpec)) error {
+	ctx, ns := context.TODO(), i.Namespace()
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		switch kind {
+		case KindDeployment:
+			deploy, err := i.kubeClient.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			mutate(&deploy.Spec.Template)
+			_, err = i.kubeClient.AppsV1().Deployments(ns).Update(ctx, deploy, metav1.UpdateOptions{})
+			return err
+		case KindStatefulSet:
+			sts, err := i.kubeClient.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			mutate(&sts.Spec.Template)
+			_, err = i.kubeClient.AppsV1().StatefulSets(ns).Update(ctx, sts, metav1.UpdateOptions{})
+			return err
+		case KindDaemonSet:
+			ds, err := i.kubeClient.AppsV1().DaemonSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			mutate(&ds.Spec.Template)
+			_, err = i.kubeClient.AppsV1().DaemonSets(ns).Update(ctx, ds, metav1.UpdateOptions{})
+			return err
+		}
+		return errors.Errorf("%s has no rolling updates", kind)
+	})
+	return errors.Wrapf(err, "updating the pod template of %s %s", kind, name)
+}
+
+// WaitForRollout waits up to Timeout until every pod of a Deployment,
+// StatefulSet or DaemonSet runs the current template and is available, or a
+// Job completed. It fails fast when a Deployment exceeded its progress
+// deadline or a Job failed.
+func (i *k8sInvocation) WaitForRollout(kind WorkloadKind, name string) error {
+	ctx, ns := context.TODO(), i.Namespace()
+	var err error
+	switch kind {
+	case KindDeployment:
+		_, err = waitForDeployment(ctx, i.kubeClient, "deployment-rollout", i.Timeout, ns, name, deploymentRolledOut)
+	case KindStatefulSet:
+		_, err = waitForStatefulSet(ctx, i.kubeClient, "statefulset-rollout", i.Timeout, ns, name, statefulSetRolledOut)
+	case KindDaemonSet:
+		_, err = waitForDaemonSet(ctx, i.kubeClient, "daemonset-rollout", i.Timeout, ns, name, daemonSetRolledOut)
+	case KindJob:
+		_, err = waitForJob(ctx, i.kubeClient, "job-complete", i.Timeout, ns, name, jobComplete)
+	default:
+		err = errors.Errorf("unknown workload kind %s", kind)
+	}
+	return errors.Wrapf(err, "waiting for the rollout of %s %s/%s", kind, ns, name)
+}
+
+func deploymentRolledOut(deploy *apps.Deployment) (bool, error) {
+	if deploy == nil {
+		return false, nil
+	}
+	for _, c := range deploy.Status.Conditions {
+		if c.Type == apps.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, errors.Errorf("deployment %s exceeded its progress deadline: %s", deploy.Name, c.Message)
+		}
+	}
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	s := deploy.Status
+	return s.ObservedGeneration >= deploy.Generation &&
+		s.UpdatedReplicas == replicas &&
+		s.AvailableReplicas == replicas &&
+		s.Replicas == replicas, nil
+}
+
+func statefulSetRolledOut(sts *apps.StatefulSet) (bool, error) {
+	if sts == nil {
+		return false, nil
+	}
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	s := sts.Status
+	return s.ObservedGeneration >= sts.Generation &&
+		s.Replicas == replicas &&
+		s.ReadyReplicas == replicas &&
+		s.UpdatedReplicas == replicas &&
+		s.CurrentRevision == s.UpdateRevision, nil
+}
+
+func daemonSetRolledOut(ds *apps.DaemonSet) (bool, error) {
+	if ds == nil {
+		return false, nil
+	}
+	s := ds.Status
+	return s.ObservedGeneration >= ds.Generation &&
+		s.UpdatedNumberScheduled == s.DesiredNumberScheduled &&
+		s.NumberAvailable == s.DesiredNumberScheduled, nil
+}
+
+func jobComplete(job *batch.Job) (bool, error) {
+	if job == nil {
+		return false, nil
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Status != core.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batch.JobComplete:
+			return true, nil
+		case batch.JobFailed:
+			return false, errors.Errorf("job %s failed: %s: %s", job.Name, c.Reason, c.Message)
+		}
+	}
+	return false, nil
+}
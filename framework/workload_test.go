@@ -0,0 +1,200 @@
+package framework
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Workloads", func() {
+	var (
+		inv    *Invocation
+		labels = map[string]string{"app": "web"}
+		ctx    = context.TODO()
+	)
+
+	BeforeEach(func() {
+		var err error
+		inv, err = newFakeFramework().Invoke()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("builds every kind around the same pod template", func() {
+		b := inv.Cluster.NewWorkload("web", labels).WithReplicas(3).WithImage("nginx:1.21")
+
+		deploy := b.DeploymentObject()
+		Expect(deploy.Namespace).To(Equal(inv.Namespace()))
+		Expect(deploy.Labels).To(Equal(labels))
+		Expect(*deploy.Spec.Replicas).To(BeEquivalentTo(3))
+		Expect(deploy.Spec.Selector.MatchLabels).To(Equal(labels))
+		Expect(deploy.Spec.Template.Labels).To(Equal(labels))
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.21"))
+		Expect(deploy.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort).To(BeEquivalentTo(80))
+
+		ds := b.DaemonSetObject()
+		Expect(ds.Spec.Selector.MatchLabels).To(Equal(labels))
+		Expect(ds.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.21"))
+
+		job := b.WithCommand("sh", "-c", "exit 0").WithCompletions(5).WithBackoffLimit(1).JobObject()
+		Expect(*job.Spec.Parallelism).To(BeEquivalentTo(3))
+		Expect(*job.Spec.Completions).To(BeEquivalentTo(5))
+		Expect(*job.Spec.BackoffLimit).To(BeEquivalentTo(1))
+		Expect(job.Spec.Template.Spec.RestartPolicy).To(Equal(core.RestartPolicyNever))
+		Expect(job.Spec.Template.Spec.Containers[0].Command).To(Equal([]string{"sh", "-c", "exit 0"}))
+
+		By("not sharing the template between objects")
+		Expect(deploy.Spec.Template.Spec.Containers[0].Command).To(BeEmpty())
+	})
+
+	It("builds StatefulSets with volume claim templates", func() {
+		claim := core.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data"},
+			Spec: core.PersistentVolumeClaimSpec{
+				AccessModes: []core.PersistentVolumeAccessMode{core.ReadWriteOnce},
+				Resources: core.ResourceRequirements{Requests: core.ResourceList{
+					core.ResourceStorage: resource.MustParse("10Gi"),
+				}},
+			},
+		}
+		sts := inv.Cluster.NewWorkload("db", labels).
+			WithVolumeClaimTemplate(claim, "/var/lib/data").
+			StatefulSetObject()
+
+		Expect(sts.Spec.ServiceName).To(Equal("db"))
+		Expect(sts.Spec.VolumeClaimTemplates).To(HaveLen(1))
+		Expect(sts.Spec.VolumeClaimTemplates[0].Name).To(Equal("data"))
+		Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).To(ConsistOf(core.VolumeMount{Name: "data", MountPath: "/var/lib/data"}))
+	})
+
+	It("deletes the claims of StatefulSet replicas with the spec", func() {
+		claim := core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data"}}
+		_, err := inv.Cluster.NewWorkload("db", labels).
+			WithReplicas(2).
+			WithVolumeClaimTemplate(claim, "/var/lib/data").
+			CreateStatefulSet()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Cluster.Scale(KindStatefulSet, "db", 3)).To(Succeed())
+
+		// The fake clientset runs no StatefulSet controller.
+		claims := inv.kubeClient.CoreV1().PersistentVolumeClaims(inv.Namespace())
+		for _, name := range []string{"data-db-0", "data-db-1", "data-db-2", "data-other-0"} {
+			_, err := claims.Create(ctx, &core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name}}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(inv.Resources()).To(HaveLen(4))
+		Expect(inv.Resources()[0].String()).To(Equal("PersistentVolumeClaim " + inv.Namespace() + "/data-db-0"))
+
+		Expect(inv.Cleanup()).To(Succeed())
+		list, err := claims.List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Items).To(ConsistOf(HaveField("Name", "data-other-0")))
+	})
+
+	It("creates, scales, restarts and deletes workloads", func() {
+		b := inv.Cluster.NewWorkload("web", labels)
+		_, err := b.CreateDeployment()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = b.CreateStatefulSet()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = b.CreateDaemonSet()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = b.CreateJob()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inv.Resources()).To(HaveLen(4))
+
+		Expect(inv.Cluster.Scale(KindDeployment, "web", 5)).To(Succeed())
+		Expect(inv.Cluster.Scale(KindStatefulSet, "web", 2)).To(Succeed())
+		Expect(inv.Cluster.Scale(KindDaemonSet, "web", 2)).To(MatchError(ContainSubstring("DaemonSet can't be scaled")))
+		Expect(inv.Cluster.Restart(KindDaemonSet, "web")).To(Succeed())
+		Expect(inv.Cluster.SetImage(KindDeployment, "web", "nginx:1.21")).To(Succeed())
+
+		deploy, err := inv.kubeClient.AppsV1().Deployments(inv.Namespace()).Get(ctx, "web", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*deploy.Spec.Replicas).To(BeEquivalentTo(5))
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.21"))
+		sts, err := inv.kubeClient.AppsV1().StatefulSets(inv.Namespace()).Get(ctx, "web", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*sts.Spec.Replicas).To(BeEquivalentTo(2))
+		ds, err := inv.kubeClient.AppsV1().DaemonSets(inv.Namespace()).Get(ctx, "web", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Annotations).To(HaveKey(restartedAtAnnotation))
+
+		Expect(inv.Cleanup()).To(Succeed())
+		deployments, err := inv.kubeClient.AppsV1().Deployments(inv.Namespace()).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deployments.Items).To(BeEmpty())
+		jobs, err := inv.kubeClient.BatchV1().Jobs(inv.Namespace()).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(jobs.Items).To(BeEmpty())
+	})
+
+	It("waits for rollouts", func() {
+		_, err := inv.Cluster.NewWorkload("web", labels).WithReplicas(2).CreateDeployment()
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(300 * time.Millisecond)
+			deploy, err := inv.kubeClient.AppsV1().Deployments(inv.Namespace()).Get(ctx, "web", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			deploy.Status = apps.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+			_, err = inv.kubeClient.AppsV1().Deployments(inv.Namespace()).UpdateStatus(ctx, deploy, metav1.UpdateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}()
+
+		Expect(inv.Cluster.WaitForRollout(KindDeployment, "web")).To(Succeed())
+	})
+
+	It("fails fast on stuck Deployments and failed Jobs", func() {
+		deploy := inv.Cluster.NewWorkload("web", labels).DeploymentObject()
+		deploy.Status.Conditions = []apps.DeploymentCondition{{
+			Type:    apps.DeploymentProgressing,
+			Status:  core.ConditionFalse,
+			Reason:  "ProgressDeadlineExceeded",
+			Message: `ReplicaSet "web-5d8f" has timed out progressing.`,
+		}}
+		job := inv.Cluster.NewWorkload("migrate", labels).JobObject()
+		job.Status.Conditions = []batch.JobCondition{{
+			Type:    batch.JobFailed,
+			Status:  core.ConditionTrue,
+			Reason:  "BackoffLimitExceeded",
+			Message: "Job has reached the specified backoff limit",
+		}}
+		client := inv.kubeClient.(*fake.Clientset)
+		Expect(client.Tracker().Add(deploy)).To(Succeed())
+		Expect(client.Tracker().Add(job)).To(Succeed())
+
+		start := time.Now()
+		Expect(inv.Cluster.WaitForRollout(KindDeployment, "web")).To(MatchError(ContainSubstring("has timed out progressing")))
+		Expect(inv.Cluster.WaitForRollout(KindJob, "migrate")).To(MatchError(ContainSubstring("BackoffLimitExceeded")))
+		Expect(time.Since(start)).To(BeNumerically("<", inv.Timeout))
+	})
+
+	It("waits for StatefulSets to reach the update revision", func() {
+		sts := inv.Cluster.NewWorkload("db", labels).StatefulSetObject()
+		sts.Status = apps.StatefulSetStatus{Replicas: 1, ReadyReplicas: 1, UpdatedReplicas: 1, CurrentRevision: "db-1", UpdateRevision: "db-2"}
+		Expect(statefulSetRolledOut(sts)).To(BeFalse())
+		sts.Status.CurrentRevision = "db-2"
+		Expect(statefulSetRolledOut(sts)).To(BeTrue())
+	})
+
+	It("lists the pods of a workload", func() {
+		for name, l := range map[string]map[string]string{"web-1": labels, "other": {"app": "other"}} {
+			pod := inv.Cluster.GetPodObject(name, l)
+			_, err := inv.kubeClient.CoreV1().Pods(inv.Namespace()).Create(ctx, pod, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		pods, err := inv.Cluster.ListPods(labels)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods).To(ConsistOf(HaveField("Name", "web-1")))
+	})
+})
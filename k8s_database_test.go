@@ -0,0 +1,83 @@
+package e2e_test
+
+import (
+	"github.com/linode/linode-k8s-e2e-tests/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Postgres", func() {
+	var (
+		err      error
+		f        *framework.Invocation
+		name     = "postgres"
+		podName  = framework.PostgresPodName(name)
+		checksum string
+	)
+
+	const (
+		schema = `CREATE TABLE e2e (id integer PRIMARY KEY, payload text NOT NULL);
+INSERT INTO e2e SELECT n, md5(random()::text) FROM generate_series(1, 10000) AS n;`
+		checksumQuery = `SELECT count(*) || ':' || md5(string_agg(payload, ',' ORDER BY id)) FROM e2e;`
+	)
+
+	BeforeEach(func() {
+		f, err = root.Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		workers, err := f.GetNodeList()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(workers)).Should(BeNumerically(">=", 2))
+
+		version, err := framework.LoadPostgresVersion(framework.PostgresCatalog, framework.DBCatalogName)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Deploying Postgres " + version.Image() + " on StorageClass " + framework.StorageClass)
+		_, err = f.Cluster.CreatePostgres(name, version, "10Gi")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Cluster.WaitForRollout(framework.KindStatefulSet, name)).To(Succeed())
+
+		By("Inserting rows")
+		_, err = f.Cluster.Psql(podName, schema)
+		Expect(err).NotTo(HaveOccurred())
+		checksum, err = f.Cluster.Psql(podName, checksumQuery)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checksum).To(HavePrefix("10000:"))
+	})
+
+	var killAndWait = func() *core.Pod {
+		pod, err := f.Cluster.GetPod(podName, f.Namespace())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Cluster.KillPod(podName)).To(Succeed())
+		Expect(f.Cluster.WaitForReplacement(pod)).To(Succeed())
+		replacement, err := f.Cluster.GetPod(podName, f.Namespace())
+		Expect(err).NotTo(HaveOccurred())
+		return replacement
+	}
+
+	Describe("Test", func() {
+		Context("On block storage", func() {
+			It("should keep its rows when the pod is killed", func() {
+				By("Killing the database pod")
+				killAndWait()
+
+				By("Checking the rows")
+				Expect(f.Cluster.Psql(podName, checksumQuery)).To(Equal(checksum))
+			})
+
+			It("should keep its rows when its node goes away", func() {
+				pod, err := f.Cluster.GetPod(podName, f.Namespace())
+				Expect(err).NotTo(HaveOccurred())
+
+				By("Cordoning " + pod.Spec.NodeName + " and killing the database pod")
+				Expect(f.CordonNode(pod.Spec.NodeName)).To(Succeed())
+				replacement := killAndWait()
+				Expect(replacement.Spec.NodeName).NotTo(Equal(pod.Spec.NodeName))
+
+				By("Checking the rows on " + replacement.Spec.NodeName)
+				Expect(f.Cluster.Psql(podName, checksumQuery)).To(Equal(checksum))
+			})
+		})
+	})
+})
@@ -3,11 +3,7 @@ package e2e_test
 import (
 	"fmt"
 	"strings"
-	"time"
 
-	"log"
-
-	"github.com/codeskyblue/go-sh"
 	"github.com/linode/linode-k8s-e2e-tests/framework"
 	"github.com/linode/linode-k8s-e2e-tests/rand"
 	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
@@ -70,54 +66,26 @@ var _ = Describe("CloudControllerManager", func() {
 		Expect(err).NotTo(HaveOccurred())
 	}
 
-	var deletePods = func(pod string) {
-		err = f.Cluster.DeletePod(pod)
-		Expect(err).NotTo(HaveOccurred())
-	}
-
-	var deleteService = func(name string) {
-		err = f.Cluster.DeleteService(name)
-		Expect(err).NotTo(HaveOccurred())
-	}
-
-	var deleteNetworkPolicy = func(name string) {
-		err = f.Cluster.DeleteNetworkPolicy(name)
-		Expect(err).NotTo(HaveOccurred())
-	}
-
 	var helmInit = func() {
 		err := framework.RunScript("helm-init.sh", kubeconfigFile)
 		Expect(err).NotTo(HaveOccurred())
 	}
 
+	// installHelmChart retries failed installs, e.g. while the chart
+	// repository is unreachable. Every attempt waits up to Timeouts.Helm and
+	// is rolled back on failure, so the retries get a budget of their own.
 	var installHelmChart = func(chartName, repoName string) {
-		var out []byte
-		var err error
+		const attempts = 3
 		Eventually(func() error {
 			switch chartName {
 			case metricsServerName:
-				out, err = sh.Command(
-					"helm", "install", chartName, repoName, "--set", "args={--kubelet-insecure-tls}", "--kubeconfig", kubeconfigFile,
-				).Output()
-
+				return f.Cluster.InstallHelmChart(chartName, repoName, "--set", "args={--kubelet-insecure-tls}")
 			case wordpressName:
-				out, err = sh.Command(
-					"helm", "install", chartName, repoName, "--version=15.2.34", "--set", "volumePermissions.enabled=true,mariadb.volumePermissions.enabled=true", "--kubeconfig", kubeconfigFile,
-				).Output()
+				return f.Cluster.InstallHelmChart(chartName, repoName, "--version=15.2.34", "--set", "volumePermissions.enabled=true,mariadb.volumePermissions.enabled=true")
 			default:
-				err = fmt.Errorf("chart name %s not handled", chartName)
+				return fmt.Errorf("chart name %s not handled", chartName)
 			}
-			return err
-		}).ShouldNot(HaveOccurred())
-
-		log.Println(string(out))
-	}
-
-	var deleteHelmChart = func(chartName string) {
-		By("Deleting Wordpress")
-		out, err := sh.Command("helm", "delete", chartName, "--kubeconfig", kubeconfigFile).Output()
-		log.Println(string(out))
-		Expect(err).NotTo(HaveOccurred())
+		}, attempts*f.Timeouts.Helm, f.RetryInterval).ShouldNot(HaveOccurred())
 	}
 
 	Describe("Test", func() {
@@ -153,17 +121,6 @@ var _ = Describe("CloudControllerManager", func() {
 					createServiceWithSelector(frontendSvcName, frontendLabels)
 				})
 
-				AfterEach(func() {
-					By("Deleting the Pods")
-					deletePods(frontendPod)
-					deletePods(backendPod)
-
-					By("Deleting the Service")
-					deleteService(frontendSvcName)
-					deleteService(backendSvcName)
-					deleteNetworkPolicy(networkPolicyName)
-				})
-
 				It("shouldn't get response from the backend service after applying network policy", func() {
 					By("Waiting for Response from the Backend Service")
 					Eventually(func() bool {
@@ -195,11 +152,6 @@ var _ = Describe("CloudControllerManager", func() {
 					installHelmChart(wordpressName, "bitnami/wordpress")
 				})
 
-				AfterEach(func() {
-					By("Deleting Wordpress Helm Chart")
-					deleteHelmChart(wordpressName)
-				})
-
 				It("should successfully deploy Wordpress helm chart and check its components", func() {
 					By("Getting Wordpress URL")
 					url, err := f.Cluster.GetHTTPEndpoints(wordpressName)
@@ -223,11 +175,6 @@ var _ = Describe("CloudControllerManager", func() {
 					installHelmChart(metricsServerName, "metrics-server/metrics-server")
 				})
 
-				AfterEach(func() {
-					By("Deleting Metrics Server Helm Chart")
-					deleteHelmChart(metricsServerName)
-				})
-
 				It("should successfully deploy Metrics Server helm chart and eventually reports metrics", func() {
 					Eventually(func() bool {
 						_, err = f.Cluster.GetPodMetrics()
@@ -252,7 +199,6 @@ var _ = Describe("CloudControllerManager", func() {
 				var (
 					serviceName = "test-service"
 					podName     = "test-pod"
-					timeout     = 2 * time.Hour
 					labels      map[string]string
 					annotations map[string]string
 				)
@@ -275,21 +221,13 @@ var _ = Describe("CloudControllerManager", func() {
 					createService(serviceName, labels, annotations)
 				})
 
-				AfterEach(func() {
-					By("Deleting Pod")
-					deletePods(podName)
-
-					By("Deleting Service with External DNS")
-					deleteService(serviceName)
-				})
-
 				It("should successfully check the external dns", func() {
 					var output string
 					Eventually(func() bool {
 						ok, out, _ := framework.GetHTTPResponse("http://" + externalDomain)
 						output = out
 						return ok
-					}, timeout).Should(BeTrue())
+					}, f.Timeouts.DNS).Should(BeTrue())
 
 					Expect(strings.Contains(output, "nginx")).Should(BeTrue())
 				})
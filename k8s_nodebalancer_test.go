@@ -0,0 +1,272 @@
+package e2e_test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/linode/linode-k8s-e2e-tests/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	annLinode = "service.beta.kubernetes.io/linode-loadbalancer-"
+
+	annDefaultProtocol      = annLinode + "default-protocol"
+	annDefaultProxyProtocol = annLinode + "default-proxy-protocol"
+	annCheckType            = annLinode + "check-type"
+	annCheckPath            = annLinode + "check-path"
+	annCheckBody            = annLinode + "check-body"
+	annCheckInterval        = annLinode + "check-interval"
+	annCheckTimeout         = annLinode + "check-timeout"
+	annCheckAttempts        = annLinode + "check-attempts"
+	annCheckPassive         = annLinode + "check-passive"
+	annThrottle             = annLinode + "throttle"
+	annPreserve             = annLinode + "preserve"
+	annNodeBalancerID       = annLinode + "nodebalancer-id"
+)
+
+// nodeBalancerCase is an entry of the NodeBalancer annotation matrix.
+type nodeBalancerCase struct {
+	annotations map[string]string
+	// port is the Service port, 80 when unset.
+	port int32
+	// tlsHost makes the port terminate TLS with a certificate for this host,
+	// the page is then requested over verified HTTPS.
+	tlsHost string
+	// request asks for the nginx page through the NodeBalancer.
+	request bool
+	// proxyProtocol serves the Service from a backend that only accepts
+	// connections with a PROXY protocol header.
+	proxyProtocol bool
+	verify        func(nb *framework.NodeBalancer, config *framework.NodeBalancerConfig)
+	// exercise checks the behaviour of the NodeBalancer at ip:port.
+	exercise func(ip string, port int32)
+}
+
+var _ = Describe("NodeBalancer annotations", func() {
+	var (
+		err         error
+		f           *framework.Invocation
+		labels      = map[string]string{"app": "nodebalancer-annotations"}
+		podName     = "nginx"
+		serviceName = "annotated"
+	)
+
+	BeforeEach(func() {
+		f, err = root.Invoke()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating Pod")
+		err = f.Cluster.CreatePod(f.Cluster.GetPodObject(podName, labels))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	// expectResponse requests the nginx page through the NodeBalancer.
+	expectResponse := func(ip string, port int32) {
+		url := fmt.Sprintf("http://%s:%d", ip, port)
+		By("Waiting for a response from " + url)
+		err := f.WaitForProbe(framework.NewProbe(url).ExpectBodyContains("nginx"))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	DescribeTable("configures the NodeBalancer",
+		func(c nodeBalancerCase) {
+			port := c.port
+			if port == 0 {
+				port = 80
+			}
+			selector := labels
+			if c.proxyProtocol {
+				selector = map[string]string{"app": "proxy-protocol"}
+				By("Creating a Pod that requires the proxy protocol")
+				err = f.Cluster.CreatePod(f.Cluster.GetProxyProtocolPodObject("proxy-protocol", selector))
+				Expect(err).NotTo(HaveOccurred())
+			}
+			svc := f.Cluster.NewService(serviceName).
+				WithSelector(selector).
+				WithAnnotations(c.annotations)
+			var ca *framework.CertificateAuthority
+			if c.tlsHost != "" {
+				By("Creating TLS Secret for " + c.tlsHost)
+				ca, err = framework.NewCertificateAuthority()
+				Expect(err).NotTo(HaveOccurred())
+				cert, err := ca.Issue(c.tlsHost)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = f.Cluster.CreateTLSSecret("nodebalancer-tls", cert)
+				Expect(err).NotTo(HaveOccurred())
+				svc = svc.WithHTTPS(port, 80, "nodebalancer-tls")
+			} else {
+				svc = svc.WithTCPPort(port, 80)
+			}
+
+			By("Creating Service")
+			_, err = svc.Create()
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Looking up the NodeBalancer of the Service")
+			nb, err := f.Cluster.GetNodeBalancer(serviceName)
+			Expect(err).NotTo(HaveOccurred())
+			config, err := nb.Config(int(port))
+			Expect(err).NotTo(HaveOccurred())
+			c.verify(nb, config)
+
+			switch {
+			case c.tlsHost != "":
+				By("Waiting for a response verified for " + c.tlsHost)
+				_, err = f.WaitForHTTPSResponse(fmt.Sprintf("https://%s:%d", nb.IPv4, port), c.tlsHost, ca)
+				Expect(err).NotTo(HaveOccurred())
+			case c.request:
+				expectResponse(nb.IPv4, port)
+			}
+			if c.exercise != nil {
+				c.exercise(nb.IPv4, port)
+			}
+		},
+		Entry("default protocol tcp", nodeBalancerCase{
+			annotations: map[string]string{annDefaultProtocol: "tcp"},
+			request:     true,
+			verify: func(_ *framework.NodeBalancer, config *framework.NodeBalancerConfig) {
+				Expect(config.Protocol).To(Equal("tcp"))
+			},
+		}),
+		Entry("default protocol http", nodeBalancerCase{
+			annotations: map[string]string{annDefaultProtocol: "http"},
+			request:     true,
+			verify: func(_ *framework.NodeBalancer, config *framework.NodeBalancerConfig) {
+				Expect(config.Protocol).To(Equal("http"))
+			},
+		}),
+		Entry("https with a TLS secret", nodeBalancerCase{
+			port:    443,
+			tlsHost: "e2e.example.com",
+			verify: func(_ *framework.NodeBalancer, config *framework.NodeBalancerConfig) {
+				Expect(config.Protocol).To(Equal("https"))
+				Expect(config.SSLCommonName).To(Equal("e2e.example.com"))
+			},
+		}),
+		Entry("http health check", nodeBalancerCase{
+			annotations: map[string]string{
+				annDefaultProtocol: "http",
+				annCheckType:       "http",
+				annCheckPath:       "/index.html",
+				annCheckInterval:   "10",
+				annCheckTimeout:    "5",
+				annCheckAttempts:   "3",
+			},
+			request: true,
+			verify: func(_ *framework.NodeBalancer, config *framework.NodeBalancerConfig) {
+				Expect(config.Check).To(Equal("http"))
+				Expect(config.CheckPath).To(Equal("/index.html"))
+				Expect(config.CheckInterval).To(Equal(10))
+				Expect(config.CheckTimeout).To(Equal(5))
+				Expect(config.CheckAttempts).To(Equal(3))
+			},
+		}),
+		Entry("http body health check", nodeBalancerCase{
+			annotations: map[string]string{
+				annDefaultProtocol: "http",
+				annCheckType:       "http_body",
+				annCheckPath:       "/",
+				annCheckBody:       "nginx",
+			},
+			request: true,
+			verify: func(_ *framework.NodeBalancer, config *framework.NodeBalancerConfig) {
+				Expect(config.Check).To(Equal("http_body"))
+				Expect(config.CheckBody).To(Equal("nginx"))
+			},
+		}),
+		Entry("connection health check without passive checks", nodeBalancerCase{
+			annotations: map[string]string{
+				annCheckType:    "connection",
+				annCheckPassive: "false",
+			},
+			request: true,
+			verify: func(_ *framework.NodeBalancer, config *framework.NodeBalancerConfig) {
+				Expect(config.Check).To(Equal("connection"))
+				Expect(config.CheckPassive).To(BeFalse())
+			},
+		}),
+		Entry("proxy protocol v2", nodeBalancerCase{
+			annotations:   map[string]string{annDefaultProxyProtocol: "v2"},
+			proxyProtocol: true,
+			verify: func(_ *framework.NodeBalancer, config *framework.NodeBalancerConfig) {
+				Expect(config.ProxyProtocol).To(Equal("v2"))
+			},
+			exercise: func(ip string, port int32) {
+				url := fmt.Sprintf("http://%s:%d", ip, port)
+				By("Waiting for the backend to receive the PROXY header through " + url)
+				// The backend drops connections without a header, so any
+				// answer proves the NodeBalancer sent one.
+				err := f.WaitForProbe(framework.NewProbe(url).ExpectBodyMatches(`^proxy-protocol client [0-9a-f.:]+\n$`))
+				Expect(err).NotTo(HaveOccurred())
+			},
+		}),
+		Entry("client connection throttle", nodeBalancerCase{
+			annotations: map[string]string{annThrottle: "2"},
+			request:     true,
+			verify: func(nb *framework.NodeBalancer, _ *framework.NodeBalancerConfig) {
+				Expect(nb.ClientConnThrottle).To(Equal(2))
+			},
+			exercise: func(ip string, port int32) {
+				By("Opening 30 connections per second against a throttle of 2")
+				// A new connection per request, keep-alive would reuse one.
+				client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+				probe := framework.NewProbe(fmt.Sprintf("http://%s:%d", ip, port)).WithClient(client).WithTimeout(5 * time.Second)
+				load := framework.NewLoadGenerator(probe, 30).WithName("throttle")
+				load.Start()
+				time.Sleep(5 * time.Second)
+				report := load.Stop()
+				Expect(report.Requests()).To(BeNumerically(">", 0))
+				Expect(report.Failures()).To(BeNumerically(">", 0), "no connection was throttled:\n%s", report)
+			},
+		}),
+	)
+
+	It("keeps the NodeBalancer of a deleted Service with preserve", func() {
+		By("Creating Service")
+		_, err = f.Cluster.NewService(serviceName).
+			WithSelector(labels).
+			WithAnnotation(annPreserve, "true").
+			Create()
+		Expect(err).NotTo(HaveOccurred())
+
+		nb, err := f.Cluster.GetNodeBalancer(serviceName)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(framework.DeleteNodeBalancer, nb.ID)
+
+		By("Deleting Service")
+		err = f.Cluster.DeleteService(serviceName)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Checking NodeBalancer " + nb.Label + " is kept")
+		Consistently(func() (*framework.NodeBalancer, error) {
+			return framework.GetNodeBalancerByID(nb.ID)
+		}, time.Minute, 10*time.Second).ShouldNot(BeNil())
+	})
+
+	It("adopts an existing NodeBalancer with nodebalancer-id", func() {
+		region, err := f.GetClusterRegion()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating NodeBalancer in " + region)
+		nb, err := f.Cluster.CreateNodeBalancer(region)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating Service")
+		_, err = f.Cluster.NewService(serviceName).
+			WithSelector(labels).
+			WithAnnotation(annNodeBalancerID, strconv.Itoa(nb.ID)).
+			Create()
+		Expect(err).NotTo(HaveOccurred())
+
+		adopted, err := f.Cluster.GetNodeBalancer(serviceName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(adopted.ID).To(Equal(nb.ID))
+
+		expectResponse(adopted.IPv4, 80)
+	})
+})
@@ -1,9 +1,12 @@
 package e2e_test
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,17 +26,51 @@ var (
 	useExisting    = false
 	kubeconfigFile = filepath.Join(homedir.HomeDir(), ".kube/config")
 	ClusterName    string
+
+	clusterConfigFile string
+	clusterSpec       = framework.DefaultClusterSpec()
+	nodeType          = clusterSpec.NodePools[0].Type
+	nodeCount         = clusterSpec.NodePools[0].Count
+	clusterTags       string
+
+	staleNamespaceTTL = 6 * time.Hour
 )
 
 func init() {
 	flag.StringVar(&framework.Image, "image", framework.Image, "registry/repository:tag")
+	flag.BoolVar(&framework.InstallCCM, "install-ccm", framework.InstallCCM, "Deploy --image as the cloud controller manager before the specs run (implied by --image)")
 	flag.StringVar(&framework.ApiToken, "api-token", os.Getenv("LINODE_API_TOKEN"), "The authentication token to use when sending requests to the Linode API")
 
-	flag.BoolVar(&useExisting, "use-existing", useExisting, "Use existing kubernetes cluster")
+	flag.StringVar(&framework.ClusterProvider, "cluster-provider", framework.ClusterProvider, "Where the cluster comes from: lke, terraform, kind or existing")
+	flag.BoolVar(&useExisting, "use-existing", useExisting, "Use existing kubernetes cluster (same as --cluster-provider=existing)")
 	flag.StringVar(&kubeconfigFile, "kubeconfig", kubeconfigFile, "To use existing cluster provide kubeconfig file")
+	flag.StringVar(&clusterConfigFile, "cluster-config", "", "YAML or JSON ClusterSpec file, the cluster flags below override it")
+	flag.StringVar(&clusterSpec.Region, "region", clusterSpec.Region, "Region to create the cluster in")
+	flag.StringVar(&clusterSpec.KubernetesVersion, "k8s-version", "", "Kubernetes version of the cluster (default latest)")
+	flag.StringVar(&nodeType, "node-type", nodeType, "Linode type of the worker nodes")
+	flag.IntVar(&nodeCount, "node-count", nodeCount, "Number of worker nodes")
+	flag.StringVar(&clusterTags, "tags", "", "Comma separated tags for the cluster")
+	flag.BoolVar(&clusterSpec.HighAvailability, "ha", false, "Create the cluster with a high availability control plane")
 	flag.StringVar(&externalDomain, "external-domain", "", "External domain for DNS tests (required when running DNS tests)")
 	flag.DurationVar(&framework.Timeout, "timeout", 5*time.Minute, "Timeout for a test to complete successfully")
 	flag.DurationVar(&framework.RetryInterval, "retry-interval", 5*time.Second, "Amount of time to wait between requests")
+	flag.DurationVar(&framework.OperationTimeouts.LoadBalancer, "lb-timeout", framework.OperationTimeouts.LoadBalancer, "Timeout for a LoadBalancer Service to get an ingress IP")
+	flag.DurationVar(&framework.OperationTimeouts.PodReady, "pod-ready-timeout", framework.OperationTimeouts.PodReady, "Timeout for a pod to become ready (default --timeout)")
+	flag.DurationVar(&framework.OperationTimeouts.DNS, "dns-timeout", framework.OperationTimeouts.DNS, "Timeout for external-dns records to propagate")
+	flag.DurationVar(&framework.OperationTimeouts.Helm, "helm-timeout", framework.OperationTimeouts.Helm, "Timeout for a helm install and its resources to become ready")
+	flag.DurationVar(&framework.OperationTimeouts.Volume, "volume-timeout", framework.OperationTimeouts.Volume, "Timeout for a block storage volume to be provisioned, attached, expanded or deleted")
+	flag.StringVar(&framework.StorageClass, "storage-class", framework.StorageClass, "StorageClass of the PersistentVolumeClaims created by the block storage specs")
+	flag.StringVar(&framework.VolumeSnapshotClass, "volume-snapshot-class", framework.VolumeSnapshotClass, "VolumeSnapshotClass of the snapshot specs, empty to create one for the driver of --storage-class")
+	flag.StringVar(&framework.DockerRegistry, "docker-registry", framework.DockerRegistry, "Registry the KubeDB images of --db-catalog are pulled from with --kubedb-image")
+	flag.BoolVar(&framework.UseKubeDBImage, "kubedb-image", framework.UseKubeDBImage, "Run the KubeDB image of --db-catalog instead of the upstream postgres image, it may need the KubeDB operator")
+	flag.StringVar(&framework.DBCatalogName, "db-catalog", framework.DBCatalogName, "PostgresVersion of manifest/pg-version.yaml the database specs run")
+	flag.DurationVar(&staleNamespaceTTL, "stale-namespace-ttl", staleNamespaceTTL, "On existing clusters, delete namespaces of earlier runs older than this")
+	flag.StringVar(&framework.ReportDir, "report-dir", framework.ReportDir, "Directory for the JUnit and JSON reports of the run, empty to disable")
+	flag.StringVar(&framework.ArtifactsDir, "artifacts-dir", framework.ArtifactsDir, "Directory for the diagnostics of failed specs, empty to disable")
+	flag.BoolVar(&framework.KeepOnFailure, "keep-on-failure", framework.KeepOnFailure, "Keep spec objects, namespaces and the cluster once a spec failed")
+	flag.StringVar(&framework.RetainedManifest, "retained-manifest", framework.RetainedManifest, "File listing the resources kept by --keep-on-failure")
+	flag.BoolVar(&framework.SkipCleanup, "skip-cleanup", framework.SkipCleanup, "Leave the objects created by each spec in place, for debugging")
+	flag.BoolVar(&framework.IsolateNamespaces, "isolate-namespaces", framework.IsolateNamespaces, "Run every spec in its own namespace, deleted when the spec ends")
 
 	var errRandom error
 
@@ -41,10 +78,19 @@ func init() {
 	if errRandom != nil {
 		panic(errRandom)
 	}
+	framework.RunID, errRandom = rand.WithRandomSuffix("e2e-run-")
+	if errRandom != nil {
+		panic(errRandom)
+	}
 }
 
 var (
-	root *framework.Framework
+	root        *framework.Framework
+	provisioner framework.ClusterProvisioner
+	teardown    = framework.NewTeardown()
+	suiteReady  bool
+
+	clusterMetadata framework.ClusterMetadata
 )
 
 func TestE2e(t *testing.T) {
@@ -54,16 +100,73 @@ func TestE2e(t *testing.T) {
 	RunSpecs(t, "e2e Suite")
 }
 
+// buildClusterSpec loads --cluster-config, if any, and applies the cluster
+// flags that were set explicitly on top of it.
+func buildClusterSpec() (framework.ClusterSpec, error) {
+	spec := framework.DefaultClusterSpec()
+	if clusterConfigFile != "" {
+		var err error
+		if spec, err = framework.LoadClusterSpec(clusterConfigFile); err != nil {
+			return spec, err
+		}
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "region":
+			spec.Region = clusterSpec.Region
+		case "k8s-version":
+			spec.KubernetesVersion = clusterSpec.KubernetesVersion
+		case "ha":
+			spec.HighAvailability = clusterSpec.HighAvailability
+		case "tags":
+			spec.Tags = strings.Split(clusterTags, ",")
+		case "node-type":
+			firstNodePool(&spec).Type = nodeType
+		case "node-count":
+			firstNodePool(&spec).Count = nodeCount
+		}
+	})
+	if spec.Name == "" {
+		spec.Name = ClusterName
+	}
+
+	return spec, spec.Validate()
+}
+
+// firstNodePool returns the pool the node flags apply to, a copy of the
+// default pool when the spec has none.
+func firstNodePool(spec *framework.ClusterSpec) *framework.NodePoolSpec {
+	if len(spec.NodePools) == 0 {
+		spec.NodePools = append(spec.NodePools, framework.DefaultClusterSpec().NodePools[0])
+	}
+	return &spec.NodePools[0]
+}
+
 var _ = BeforeSuite(func() {
 
-	if !useExisting {
-		err := framework.CreateCluster(ClusterName)
-		Expect(err).NotTo(HaveOccurred())
-		dir, err := os.Getwd()
-		Expect(err).NotTo(HaveOccurred())
-		kubeconfigFile = filepath.Join(dir, ClusterName+".conf")
+	if useExisting {
+		framework.ClusterProvider = framework.ProviderExisting
 	}
 
+	var err error
+	provisioner, err = framework.NewClusterProvisioner(framework.ClusterProvider, kubeconfigFile)
+	Expect(err).NotTo(HaveOccurred())
+
+	spec, err := buildClusterSpec()
+	Expect(err).NotTo(HaveOccurred())
+	// --cluster-config may name the cluster, the retained manifest has to
+	// record the name it was created under.
+	ClusterName = spec.Name
+
+	By("Provisioning cluster with " + provisioner.Describe() + " for run " + framework.RunID)
+	// Registered before Create so a half created cluster is deleted as well.
+	teardown.Add("cluster", provisioner.Delete)
+	err = provisioner.Create(context.TODO(), spec)
+	Expect(err).NotTo(HaveOccurred())
+	kubeconfigFile, err = provisioner.Kubeconfig(context.TODO())
+	Expect(err).NotTo(HaveOccurred())
+
 	By("Using kubeconfig from " + kubeconfigFile)
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigFile)
 	Expect(err).NotTo(HaveOccurred())
@@ -77,16 +180,89 @@ var _ = BeforeSuite(func() {
 	root, err = framework.New(config, kubeClient, kubeconfigFile, metricsClient)
 	Expect(err).NotTo(HaveOccurred())
 
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "image" {
+			framework.InstallCCM = true
+		}
+	})
+	var ccmDigest string
+	if framework.InstallCCM {
+		By("Deploying CCM image " + framework.Image)
+		ccm := root.NewCCMInstaller(framework.Image, framework.ApiToken, spec.Region)
+		if framework.ClusterProvider == framework.ProviderExisting {
+			// Registered before Install so a half done patch is undone as well.
+			teardown.Add("ccm", ccm.Restore)
+		}
+		ccmDigest, err = ccm.Install(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	clusterMetadata, err = root.ClusterMetadata()
+	if err != nil {
+		fmt.Fprintf(GinkgoWriter, "Collecting cluster metadata for the reports: %v\n", err)
+	}
+	clusterMetadata.CCMImageDigest = ccmDigest
+	if framework.ClusterProvider != framework.ProviderExisting {
+		clusterMetadata.Name = spec.Name
+	}
+
 	By("Using namespace " + root.Namespace())
 
+	if framework.ClusterProvider == framework.ProviderExisting {
+		By("Deleting namespaces of earlier runs")
+		err = root.DeleteStaleNamespaces(staleNamespaceTTL)
+		Expect(err).NotTo(HaveOccurred())
+
+		// The cluster outlives the run, so the namespace has to go explicitly.
+		teardown.Add("namespace "+root.Namespace(), func(context.Context) error {
+			return root.DeleteNamespace()
+		})
+	}
+
 	// Create namespace
 	err = root.CreateNamespace()
 	Expect(err).NotTo(HaveOccurred())
+
+	suiteReady = true
+})
+
+var _ = ReportAfterEach(func(report SpecReport) {
+	if report.Failed() {
+		framework.MarkFailed()
+	}
+	if root != nil {
+		root.ReportDiagnostics(report)
+	}
+})
+
+var _ = ReportAfterSuite("JUnit and JSON reports", func(report Report) {
+	// Set here as well in case BeforeSuite failed before reaching the cluster.
+	clusterMetadata.Provider = framework.ClusterProvider
+	clusterMetadata.CCMImage = framework.Image
+	err := framework.WriteReports(report, clusterMetadata)
+	Expect(err).NotTo(HaveOccurred())
 })
 
 var _ = AfterSuite(func() {
-	if !useExisting {
-		err := framework.DeleteCluster()
+	if !suiteReady {
+		framework.MarkFailed()
+	}
+
+	if framework.KeepResources() {
+		namespace := ""
+		if root != nil {
+			namespace = root.Namespace()
+		}
+		err := framework.WriteRetained(framework.RetainedCluster{
+			Provider:   framework.ClusterProvider,
+			Name:       ClusterName,
+			Kubeconfig: kubeconfigFile,
+		}, namespace)
 		Expect(err).NotTo(HaveOccurred())
+		return
 	}
+
+	By("Tearing down run " + framework.RunID)
+	err := teardown.Run(context.TODO())
+	Expect(err).NotTo(HaveOccurred())
 })
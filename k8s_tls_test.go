@@ -0,0 +1,70 @@
+package e2e_test
+
+import (
+	"fmt"
+
+	"github.com/linode/linode-k8s-e2e-tests/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS-terminating LoadBalancer", func() {
+	var (
+		err         error
+		f           *framework.Invocation
+		ca          *framework.CertificateAuthority
+		labels      = map[string]string{"app": "tls-termination"}
+		host        = "tls.e2e.example.com"
+		secretName  = "tls-termination"
+		serviceName = "tls-termination"
+	)
+
+	BeforeEach(func() {
+		f, err = root.Invoke()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Generating a CA and a serving certificate for " + host)
+		ca, err = framework.NewCertificateAuthority()
+		Expect(err).NotTo(HaveOccurred())
+		cert, err := ca.Issue(host)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating TLS Secret")
+		_, err = f.Cluster.CreateTLSSecret(secretName, cert)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating Pod")
+		err = f.Cluster.CreatePod(f.Cluster.GetPodObject("nginx", labels))
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating Service with HTTPS on 443")
+		_, err = f.Cluster.NewService(serviceName).
+			WithSelector(labels).
+			WithTCPPort(80, 80).
+			WithHTTPS(443, 80, secretName).
+			Create()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("serves the generated certificate on 443 and plain HTTP on 80", func() {
+		nb, err := f.Cluster.GetNodeBalancer(serviceName)
+		Expect(err).NotTo(HaveOccurred())
+		url := fmt.Sprintf("https://%s:443", nb.IPv4)
+
+		By("Requesting " + url + " for " + host)
+		resp, err := f.WaitForHTTPSResponse(url, host, ca)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Body).To(ContainSubstring("nginx"))
+		Expect(resp.Chain).To(HaveLen(2))
+		Expect(resp.Chain[0].Subject.CommonName).To(Equal(host))
+
+		By("Checking the certificate is rejected for another name")
+		_, err = ca.GetHTTPSResponse(url, "other.e2e.example.com")
+		Expect(err).To(HaveOccurred())
+
+		By("Checking port 80 still serves plain HTTP")
+		err = f.WaitForProbe(framework.NewProbe(fmt.Sprintf("http://%s:80", nb.IPv4)).ExpectBodyContains("nginx"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,181 @@
+package e2e_test
+
+import (
+	"github.com/linode/linode-k8s-e2e-tests/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Block Storage", func() {
+	var (
+		err       error
+		f         *framework.Invocation
+		workers   []string
+		claimName = "data"
+		fileName  = "e2e.txt"
+	)
+
+	BeforeEach(func() {
+		f, err = root.Invoke()
+		Expect(err).NotTo(HaveOccurred())
+		workers, err = f.GetNodeList()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(workers)).Should(BeNumerically(">=", 2))
+	})
+
+	var createClaim = func(pvc *core.PersistentVolumeClaim) *core.PersistentVolume {
+		_, err = f.Cluster.CreatePersistentVolumeClaim(pvc)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.Cluster.WaitForPersistentVolumeClaimBound(pvc.Name)
+		Expect(err).NotTo(HaveOccurred())
+		pv, err := f.Cluster.GetPersistentVolume(pvc.Name)
+		Expect(err).NotTo(HaveOccurred())
+		return pv
+	}
+
+	var createPodOnNode = func(podName, node string) {
+		err = f.Cluster.CreatePod(f.Cluster.GetVolumePodObject(podName, claimName, node))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	var deletePod = func(podName string) {
+		Expect(f.Cluster.DeletePod(podName)).To(Succeed())
+		_, err = f.Cluster.WaitForPod(podName, func(pod *core.Pod) (bool, error) {
+			return pod == nil, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	var attachedTo = func(pv *core.PersistentVolume) int {
+		v, err := f.Cluster.WaitForBlockStorageVolume(pv, func(v *framework.BlockStorageVolume) bool {
+			return v != nil && v.LinodeID != nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return *v.LinodeID
+	}
+
+	Describe("Test", func() {
+		Context("A PersistentVolumeClaim", func() {
+			var pv *core.PersistentVolume
+
+			BeforeEach(func() {
+				By("Creating a 10Gi claim of StorageClass " + framework.StorageClass)
+				pv = createClaim(f.Cluster.GetPersistentVolumeClaimObject(claimName, "10Gi"))
+
+				By("Writing to the volume on " + workers[0])
+				createPodOnNode("writer", workers[0])
+				Expect(f.Cluster.WriteVolumeFile("writer", fileName, f.Namespace())).To(Succeed())
+			})
+
+			It("should keep its data when the pod moves to another worker", func() {
+				first := attachedTo(pv)
+
+				By("Deleting the pod to detach the volume")
+				deletePod("writer")
+
+				By("Reading the volume on " + workers[1])
+				createPodOnNode("reader", workers[1])
+				Expect(f.Cluster.ReadVolumeFile("reader", fileName)).To(Equal(f.Namespace()))
+				Expect(attachedTo(pv)).NotTo(Equal(first))
+			})
+
+			It("should be expanded while mounted", func() {
+				By("Expanding the claim to 20Gi")
+				Expect(f.Cluster.ExpandPersistentVolumeClaim(claimName, "20Gi")).To(Succeed())
+				_, err = f.Cluster.WaitForPersistentVolumeClaimCapacity(claimName, "20Gi")
+				Expect(err).NotTo(HaveOccurred())
+
+				By("Checking the Linode volume and the filesystem grew")
+				_, err = f.Cluster.WaitForBlockStorageVolume(pv, func(v *framework.BlockStorageVolume) bool {
+					return v != nil && v.Size >= 20
+				})
+				Expect(err).NotTo(HaveOccurred())
+				size, err := f.Cluster.VolumeFilesystemSize("writer")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(size).To(BeNumerically(">", int64(15)<<30))
+				Expect(f.Cluster.ReadVolumeFile("writer", fileName)).To(Equal(f.Namespace()))
+			})
+
+			It("should delete the Linode volume with the claim", func() {
+				By("Deleting the pod and the claim")
+				deletePod("writer")
+				Expect(f.Cluster.DeletePersistentVolumeClaim(claimName)).To(Succeed())
+
+				By("Waiting for the volume to be reclaimed")
+				Expect(f.Cluster.WaitForPersistentVolumeDeleted(pv.Name)).To(Succeed())
+				_, err = f.Cluster.WaitForBlockStorageVolume(pv, func(v *framework.BlockStorageVolume) bool {
+					return v == nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("A VolumeSnapshot", func() {
+			It("should restore the data into a new claim", func() {
+				supported, err := f.Cluster.SnapshotsSupported()
+				Expect(err).NotTo(HaveOccurred())
+				if !supported {
+					Skip("the cluster does not serve the snapshot.storage.k8s.io/v1 API")
+				}
+
+				snapshotClass := framework.VolumeSnapshotClass
+				if snapshotClass == "" {
+					snapshotClass = f.Namespace() + "-snapshots"
+					By("Creating VolumeSnapshotClass " + snapshotClass)
+					_, err = f.Cluster.CreateVolumeSnapshotClass(snapshotClass)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				By("Writing checksummed data to a new claim")
+				createClaim(f.Cluster.GetPersistentVolumeClaimObject(claimName, "10Gi"))
+				createPodOnNode("writer", "")
+				checksum, err := f.Cluster.WriteRandomVolumeFile("writer", fileName, 64)
+				Expect(err).NotTo(HaveOccurred())
+
+				By("Taking a snapshot")
+				_, err = f.Cluster.CreateVolumeSnapshot(f.Cluster.GetVolumeSnapshotObject("backup", claimName, snapshotClass))
+				Expect(err).NotTo(HaveOccurred())
+				_, err = f.Cluster.WaitForVolumeSnapshotReady("backup")
+				Expect(err).NotTo(HaveOccurred())
+
+				By("Restoring the snapshot into a new claim in another pod")
+				createClaim(f.Cluster.GetRestoredClaimObject("restored", "10Gi", "backup"))
+				err = f.Cluster.CreatePod(f.Cluster.GetVolumePodObject("reader", "restored", ""))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(f.Cluster.VolumeFileChecksum("reader", fileName)).To(Equal(checksum))
+			})
+		})
+
+		Context("A PersistentVolumeClaim with the Retain reclaim policy", func() {
+			It("should keep the Linode volume after the claim is deleted", func() {
+				storageClass := f.Namespace() + "-retain"
+				By("Creating StorageClass " + storageClass)
+				_, err = f.Cluster.CreateStorageClass(storageClass, core.PersistentVolumeReclaimRetain)
+				Expect(err).NotTo(HaveOccurred())
+
+				pvc := f.Cluster.GetPersistentVolumeClaimObject(claimName, "10Gi")
+				pvc.Spec.StorageClassName = &storageClass
+				pv := createClaim(pvc)
+				// The retained volumes outlive the claim, remove them last.
+				DeferCleanup(func() {
+					Expect(f.Cluster.DeletePersistentVolume(pv.Name)).To(Succeed())
+					Expect(f.Cluster.DeleteBlockStorageVolume(pv)).To(Succeed())
+				})
+
+				By("Deleting the claim")
+				Expect(f.Cluster.DeletePersistentVolumeClaim(claimName)).To(Succeed())
+
+				By("Checking the volume was released but kept")
+				_, err = f.Cluster.WaitForPersistentVolume(pv.Name, func(pv *core.PersistentVolume) (bool, error) {
+					return pv != nil && pv.Status.Phase == core.VolumeReleased, nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+				v, err := framework.GetBlockStorageVolume(pv)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(v).NotTo(BeNil())
+			})
+		})
+	})
+})
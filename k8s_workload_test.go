@@ -0,0 +1,71 @@
+package e2e_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/linode/linode-k8s-e2e-tests/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("LoadBalancer availability", func() {
+	var (
+		err         error
+		f           *framework.Invocation
+		labels      = map[string]string{"app": "rolling-web"}
+		name        = "rolling-web"
+		serviceName = "rolling-web"
+	)
+
+	BeforeEach(func() {
+		f, err = root.Invoke()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating Deployment with 3 replicas")
+		_, err = f.Cluster.NewWorkload(name, labels).
+			WithReplicas(3).
+			WithPodSpec(func(spec *core.PodSpec) {
+				// Leave kube-proxy time to drop the endpoint before nginx quits.
+				spec.Containers[0].Lifecycle = &core.Lifecycle{
+					PreStop: &core.Handler{Exec: &core.ExecAction{Command: []string{"sleep", "10"}}},
+				}
+				spec.Containers[0].ReadinessProbe = &core.Probe{
+					Handler: core.Handler{HTTPGet: &core.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)}},
+				}
+			}).
+			CreateDeployment()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Cluster.WaitForRollout(framework.KindDeployment, name)).To(Succeed())
+
+		By("Creating Service")
+		_, err = f.Cluster.NewService(serviceName).WithSelector(labels).Create()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("keeps serving while the backends roll", func() {
+		nb, err := f.Cluster.GetNodeBalancer(serviceName)
+		Expect(err).NotTo(HaveOccurred())
+		url := fmt.Sprintf("http://%s:80", nb.IPv4)
+		By("Waiting for " + url + " to answer steadily")
+		Expect(f.WaitForProbe(framework.NewProbe(url).ExpectConsecutiveSuccesses(3))).To(Succeed())
+
+		probe := framework.NewProbe(url).WithTimeout(5 * time.Second).ExpectBodyContains("nginx")
+
+		load := framework.NewLoadGenerator(probe, 10).WithName("rolling restart")
+		load.Start()
+		defer load.Stop()
+
+		By("Restarting the Deployment")
+		start := time.Now()
+		Expect(f.Cluster.Restart(framework.KindDeployment, name)).To(Succeed())
+		Expect(f.Cluster.WaitForRollout(framework.KindDeployment, name)).To(Succeed())
+		end := time.Now()
+
+		report := load.Stop()
+		Expect(report.Between(start, end).CheckAvailability(0.99)).To(Succeed())
+	})
+})
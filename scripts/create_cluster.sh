@@ -6,24 +6,36 @@ set -o nounset
 
 export LINODE_API_TOKEN="$1"
 export CLUSTER_NAME="$2"
+export REGION="${3:-eu-west}"
+export NODE_TYPE="${4:-g6-standard-2}"
+export NODES="${5:-2}"
+export TAGS="${6:-}"
 
+TAG_LIST=""
+IFS=',' read -r -a tags <<< "${TAGS}"
+for tag in ${tags[@]+"${tags[@]}"}; do
+  TAG_LIST="${TAG_LIST}\"${tag}\", "
+done
 
 cat > cluster.tf <<EOF
 variable "server_type_node" {
-  default = "g6-standard-2"
+  default = "${NODE_TYPE}"
 }
 variable "nodes" {
-  default = 2
+  default = ${NODES}
 }
 variable "server_type_master" {
   default = "g6-standard-2"
 }
 variable "region" {
-  default = "eu-west"
+  default = "${REGION}"
 }
 variable "ssh_public_key" {
   default = "${HOME}/.ssh/id_rsa.pub"
 }
+variable "tags" {
+  default = [${TAG_LIST}]
+}
 module "k8s" {
   source  = "git::https://github.com/linode/terraform-linode-k8s.git?ref=v0.3.0"
   linode_token = "${LINODE_API_TOKEN}"
@@ -33,6 +45,7 @@ module "k8s" {
   server_type_master = var.server_type_master
   region = var.region
   ssh_public_key = var.ssh_public_key
+  tags = var.tags
 }
 EOF
 